@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// quit tells the REPL to stop, without waiting for it.
+func quit(stop chan<- struct{}) {
+	select {
+	case stop <- struct{}{}:
+	default:
+	}
+}
+
+// nextInput reads the next command, or gives up once stop fires. The client
+// exits then, and the read it gave up on with it.
+func nextInput(stop <-chan struct{}) ([]string, bool) {
+	lines := make(chan []string, 1)
+	go func() {
+		lines <- gamelogic.GetInput()
+	}()
+	select {
+	case inputs := <-lines:
+		return inputs, true
+	case <-stop:
+		return nil, false
+	}
+}
+
+// handlerKick ends the game, the server already ended the session.
+func handlerKick(stop chan<- struct{}) func(routing.Kick) pubsub.HandlerOutcome {
+	return func(k routing.Kick) pubsub.HandlerOutcome {
+		fmt.Printf("\nYou were kicked from the server%s\n", reason(k.Reason))
+		quit(stop)
+		return pubsub.Ack
+	}
+}
+
+// handlerBan also forgets the saved session, the server would refuse it
+// anyway.
+func handlerBan(sessionPath string, stop chan<- struct{}) func(routing.Ban) pubsub.HandlerOutcome {
+	return func(b routing.Ban) pubsub.HandlerOutcome {
+		fmt.Printf("\nYou were banned from the server%s\n", reason(b.Reason))
+		if err := os.Remove(sessionPath); err != nil && !os.IsNotExist(err) {
+			log.Printf("could not remove your session: %v\n", err)
+		}
+		quit(stop)
+		return pubsub.Ack
+	}
+}
+
+func handlerMute(muted *atomic.Bool) func(routing.Mute) pubsub.HandlerOutcome {
+	return func(m routing.Mute) pubsub.HandlerOutcome {
+		defer fmt.Print("> ")
+		muted.Store(m.Muted)
+		if m.Muted {
+			fmt.Println("\nYou were muted, your game logs are no longer recorded.")
+		} else {
+			fmt.Println("\nYou are no longer muted.")
+		}
+		return pubsub.Ack
+	}
+}
+
+func handlerBroadcast(b routing.Broadcast) pubsub.HandlerOutcome {
+	defer fmt.Print("> ")
+	fmt.Printf("\n[server %s] %s\n", b.SentAt.Format(time.TimeOnly), b.Message)
+	return pubsub.Ack
+}
+
+func handlerAnnouncement(a routing.Announcement) pubsub.HandlerOutcome {
+	defer fmt.Print("> ")
+	fmt.Printf("\n[%s %s] %s\n", a.Game, a.SentAt.Format(time.TimeOnly), a.Message)
+	return pubsub.Ack
+}
+
+func reason(r string) string {
+	if r == "" {
+		return "."
+	}
+	return ": " + r
+}
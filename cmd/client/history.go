@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/history"
+)
+
+const (
+	snapshotEvery  = 100
+	defaultHistory = 20
+)
+
+// recordEvent appends the events of gs to store, snapshotting the state every
+// snapshotEvery events so rebuilding it stays quick.
+func recordEvent(store *history.Store, gs *gamelogic.GameState) func(gamelogic.Event) {
+	return func(e gamelogic.Event) {
+		e, pending, err := store.Append(e)
+		if err != nil {
+			log.Printf("could not record event: %v\n", err)
+			return
+		}
+		if pending < snapshotEvery {
+			return
+		}
+		if err := store.SaveSnapshot(gs.Snapshot(e.Seq)); err != nil {
+			log.Printf("could not snapshot your state: %v\n", err)
+		}
+	}
+}
+
+func printEvents(events []gamelogic.Event) {
+	if len(events) == 0 {
+		fmt.Println("Nothing happened yet.")
+		return
+	}
+	for _, e := range events {
+		fmt.Printf("#%d %v %s\n", e.Seq, e.Time.Format(time.TimeOnly), describeEvent(e))
+	}
+}
+
+func describeEvent(e gamelogic.Event) string {
+	switch e.Kind {
+	case gamelogic.EventSpawn:
+		return fmt.Sprintf("spawned a(n) %s in %s with id %v", e.Spawn.Unit.Rank, e.Spawn.Unit.Location, e.Spawn.Unit.ID)
+	case gamelogic.EventMove:
+		return fmt.Sprintf("moved %v to %s", e.Move.UnitIDs, e.Move.ToLocation)
+	case gamelogic.EventState:
+		return fmt.Sprintf("server update: %d unit(s) changed, %d removed, treasury %d", len(e.State.Upserted), len(e.State.Removed), e.State.Treasury)
+	case gamelogic.EventWar:
+		if e.War.IsDraw() {
+			return fmt.Sprintf("war between %s and %s in %s ended in a draw", e.War.Attacker, e.War.Defender, e.War.Location)
+		}
+		return fmt.Sprintf("%s won a war against %s in %s", e.War.Winner, e.War.Loser, e.War.Location)
+	case gamelogic.EventPause:
+		if e.Playing.IsPaused {
+			return "game paused"
+		}
+		return "game running"
+	case gamelogic.EventTurn:
+		return fmt.Sprintf("turn %d started", e.Turn)
+	case gamelogic.EventGameOver:
+		return "game over"
+	default:
+		return string(e.Kind)
+	}
+}
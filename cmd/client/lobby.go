@@ -0,0 +1,206 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var errLeftLobby = errors.New("left the lobby")
+
+// readyGame holds the game the matchmaker created for the player, until the
+// lobby picks it up.
+type readyGame struct {
+	game string
+	mu   *sync.Mutex
+}
+
+func (rg *readyGame) set(game string) {
+	rg.mu.Lock()
+	defer rg.mu.Unlock()
+	rg.game = game
+}
+
+func (rg *readyGame) take() string {
+	rg.mu.Lock()
+	defer rg.mu.Unlock()
+	game := rg.game
+	rg.game = ""
+	return game
+}
+
+// enterGame joins game, or lets the player pick a game or queue for a match
+// in the lobby when game is empty or can not be joined.
+func enterGame(conn *amqp.Connection, sess session, game string, local *gamelogic.Ruleset) (string, gamelogic.JoinResponse, error) {
+	if game != "" {
+		joined, err := join(conn, sess, game, local)
+		if err == nil {
+			return game, joined, nil
+		}
+		log.Println(err)
+	}
+
+	ready := &readyGame{mu: &sync.Mutex{}}
+	err := pubsub.SubscribeJSONFrom(
+		conn,
+		routing.ExchangePerilDirect,
+		fmt.Sprintf("%s.%s", routing.LobbyQueuePrefix, sess.username),
+		fmt.Sprintf("%s.%s", routing.MatchesPrefix, sess.username),
+		pubsub.QueueTransient,
+		sess.fromServer(),
+		fromServer(handlerMatchNotice(ready)),
+	)
+	if err != nil {
+		return "", gamelogic.JoinResponse{}, err
+	}
+
+	gamelogic.PrintLobbyHelp()
+	for {
+		inputs := gamelogic.GetInput()
+		if game := ready.take(); game != "" {
+			joined, err := join(conn, sess, game, local)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			return game, joined, nil
+		}
+		if len(inputs) == 0 {
+			continue
+		}
+		switch inputs[0] {
+		case "games":
+			resp, err := rooms(conn, gamelogic.RoomsRequest{})
+			if err != nil {
+				log.Printf("could not list games: %v\n", err)
+				continue
+			}
+			printRooms(resp.Rooms)
+		case "create", "join":
+			if len(inputs) < 2 {
+				log.Printf("%s command needs a game ID\n", inputs[0])
+				continue
+			}
+			game := inputs[1]
+			if inputs[0] == "create" {
+				if _, err := rooms(conn, gamelogic.RoomsRequest{Create: game, Token: sess.token}); err != nil {
+					log.Printf("could not create game: %v\n", err)
+					continue
+				}
+			}
+			joined, err := join(conn, sess, game, local)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			return game, joined, nil
+		case "queue":
+			if len(inputs) < 2 {
+				log.Println("queue command needs the number of players")
+				continue
+			}
+			size, err := strconv.Atoi(inputs[1])
+			if err != nil {
+				log.Printf("invalid number of players %v\n", inputs[1])
+				continue
+			}
+			resp, err := matchmaking(conn, gamelogic.MatchRequest{
+				Action:    gamelogic.MatchQueue,
+				Username:  sess.username,
+				Size:      size,
+				RulesHash: local.Hash(),
+				Rules:     local,
+				Token:     sess.token,
+			})
+			if err != nil {
+				log.Printf("could not queue: %v\n", err)
+				continue
+			}
+			fmt.Printf("Queued for a %d player game, you are #%d in line\n", size, resp.Position)
+		case "accept", "cancel":
+			_, err := matchmaking(conn, gamelogic.MatchRequest{Action: gamelogic.MatchAction(inputs[0]), Username: sess.username, Token: sess.token})
+			if err != nil {
+				log.Printf("%s error: %v\n", inputs[0], err)
+			}
+		case "help":
+			gamelogic.PrintLobbyHelp()
+		case "quit":
+			gamelogic.PrintQuit()
+			return "", gamelogic.JoinResponse{}, errLeftLobby
+		default:
+			log.Println("unknown command")
+		}
+	}
+}
+
+func rooms(conn *amqp.Connection, req gamelogic.RoomsRequest) (gamelogic.RoomsResponse, error) {
+	resp, err := pubsub.CallJSON[gamelogic.RoomsRequest, gamelogic.RoomsResponse](
+		conn, routing.ExchangeDefault, routing.RoomsQueue, req, joinTimeout,
+	)
+	if err != nil {
+		return resp, err
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+func matchmaking(conn *amqp.Connection, req gamelogic.MatchRequest) (gamelogic.MatchResponse, error) {
+	resp, err := pubsub.CallJSON[gamelogic.MatchRequest, gamelogic.MatchResponse](
+		conn, routing.ExchangeDefault, routing.MatchmakingQueue, req, joinTimeout,
+	)
+	if err != nil {
+		return resp, err
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
+
+func handlerMatchNotice(ready *readyGame) func(gamelogic.MatchNotice) pubsub.HandlerOutcome {
+	return func(notice gamelogic.MatchNotice) pubsub.HandlerOutcome {
+		defer fmt.Print("> ")
+		fmt.Println()
+		switch notice.Kind {
+		case gamelogic.MatchFound:
+			fmt.Printf("Match found with %s! Type 'accept' within %v or 'cancel'.\n",
+				strings.Join(notice.Players, ", "), time.Until(notice.Deadline).Round(time.Second))
+		case gamelogic.MatchReady:
+			ready.set(notice.Game)
+			fmt.Printf("Everyone accepted, game %s is ready. Press enter to join it.\n", notice.Game)
+		case gamelogic.MatchCancelled:
+			fmt.Printf("The match was called off: %s\n", notice.Reason)
+		}
+		return pubsub.Ack
+	}
+}
+
+func printRooms(rooms []gamelogic.RoomInfo) {
+	if len(rooms) == 0 {
+		fmt.Println("There are no games yet, create one!")
+		return
+	}
+	for _, info := range rooms {
+		status := "running"
+		switch {
+		case info.Over:
+			status = "over"
+		case info.Paused:
+			status = "paused"
+		case info.TurnBased:
+			status = fmt.Sprintf("turn %d", info.Turn)
+		}
+		fmt.Printf("* %s: %d player(s), %s\n", info.ID, info.Players, status)
+	}
+}
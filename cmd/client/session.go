@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const loginAttempts = 3
+
+// serverMaxAge is how long after the server sent them its messages are
+// accepted.
+const serverMaxAge = 5 * time.Minute
+
+var errNotFromServer = errors.New("not signed by the server")
+
+type session struct {
+	username  string
+	token     string
+	keyID     string
+	key       []byte
+	serverKey ed25519.PublicKey
+}
+
+func newSession(resp gamelogic.AuthResponse) session {
+	return session{username: resp.Username, token: resp.Token, keyID: resp.KeyID, key: resp.SessionKey, serverKey: resp.ServerKey}
+}
+
+// signed signs a message with the session key, so the server knows it is
+// from the player.
+func (s session) signed() pubsub.PublishOption {
+	return pubsub.SignedBy(s.username, s.keyID, func(payload []byte) []byte {
+		h := hmac.New(sha256.New, s.key)
+		h.Write(payload)
+		return h.Sum(nil)
+	})
+}
+
+// fromServer accepts messages only when the server signed them.
+func (s session) fromServer() *pubsub.Authenticator {
+	return pubsub.NewAuthenticator(func(sender, _ string, payload, sig []byte) error {
+		if sender != routing.ServerName || len(s.serverKey) != ed25519.PublicKeySize || !ed25519.Verify(s.serverKey, payload, sig) {
+			return errNotFromServer
+		}
+		return nil
+	}, pubsub.WithMaxAge(serverMaxAge))
+}
+
+func fromServer[T any](handler func(T) pubsub.HandlerOutcome) func(string, T) pubsub.HandlerOutcome {
+	return func(_ string, val T) pubsub.HandlerOutcome {
+		return handler(val)
+	}
+}
+
+// authenticate resumes the session saved at path, or logs the player in and
+// saves the new session there.
+func authenticate(conn *amqp.Connection, path string) (session, error) {
+	if data, err := os.ReadFile(path); err == nil {
+		resp, err := callAuth(conn, gamelogic.AuthRequest{Action: gamelogic.AuthVerify, Token: strings.TrimSpace(string(data))})
+		if err == nil {
+			fmt.Printf("Welcome back, %s!\n", resp.Username)
+			return newSession(resp), nil
+		}
+		log.Printf("your saved session is no longer valid: %v\n", err)
+	}
+
+	for attempt := 0; attempt < loginAttempts; attempt++ {
+		req, err := gamelogic.ClientWelcome()
+		if err != nil {
+			return session{}, err
+		}
+		resp, err := callAuth(conn, req)
+		if err != nil {
+			log.Printf("%s failed: %v\n", req.Action, err)
+			continue
+		}
+		if err := os.WriteFile(path, []byte(resp.Token), 0600); err != nil {
+			log.Printf("could not save your session: %v\n", err)
+		}
+		fmt.Printf("Welcome, %s!\n", resp.Username)
+		return newSession(resp), nil
+	}
+	return session{}, errors.New("too many failed attempts")
+}
+
+func callAuth(conn *amqp.Connection, req gamelogic.AuthRequest) (gamelogic.AuthResponse, error) {
+	resp, err := pubsub.CallJSON[gamelogic.AuthRequest, gamelogic.AuthResponse](
+		conn, routing.ExchangeDefault, routing.AuthQueue, req, joinTimeout,
+	)
+	if err != nil {
+		return resp, err
+	}
+	if resp.Error != "" {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
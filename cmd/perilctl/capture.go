@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/capture"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+const roomsTimeout = 5 * time.Second
+
+func runCapture(url string, args []string) error {
+	fs := flag.NewFlagSet("capture", flag.ContinueOnError)
+	out := fs.String("o", "capture.jsonl", "file to write captured messages to")
+	games := fs.String("games", "", "comma-separated games whose direct messages to capture, defaults to every game the server hosts")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	conn, ch, err := dial(url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	gameIDs := []string{}
+	if *games != "" {
+		gameIDs = strings.Split(*games, ",")
+	} else {
+		resp, err := pubsub.CallJSON[gamelogic.RoomsRequest, gamelogic.RoomsResponse](
+			conn, routing.ExchangeDefault, routing.RoomsQueue, gamelogic.RoomsRequest{}, roomsTimeout,
+		)
+		if err != nil {
+			fmt.Printf("could not list the server's games, capturing topic messages only: %v\n", err)
+		}
+		for _, info := range resp.Rooms {
+			gameIDs = append(gameIDs, info.ID)
+		}
+	}
+
+	f, err := os.OpenFile(*out, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open capture file: %v", err)
+	}
+	defer f.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("capturing to %s, press Ctrl+C to stop\n", *out)
+	n, err := capture.Record(ctx, ch, captureTaps(gameIDs), f)
+	fmt.Printf("%d message(s) captured\n", n)
+	return err
+}
+
+func runReplay(url string, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	speed := fs.Float64("speed", 1, "replay speed multiplier, 0 replays without delays")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("usage: replay [-speed N] <file>")
+	}
+	if *speed < 0 {
+		return errors.New("speed must not be negative")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("could not open capture file: %v", err)
+	}
+	defer f.Close()
+	records, err := capture.ReadAll(f)
+	if err != nil {
+		return err
+	}
+
+	conn, ch, err := dial(url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	replayable := []capture.Entry{}
+	for _, rec := range records {
+		if isRPC(rec.Exchange, rec.RoutingKey) {
+			fmt.Printf("skipping RPC request %s/%s, its reply address was not captured\n", rec.Exchange, rec.RoutingKey)
+			continue
+		}
+		replayable = append(replayable, rec)
+	}
+	records = replayable
+
+	fmt.Printf("replaying %d message(s) at %vx\n", len(records), *speed)
+	return capture.Replay(ctx, ch, records, *speed, func(rec capture.Entry) {
+		fmt.Printf("* %v %s/%s (%s)\n", rec.Time.Format("15:04:05.000"), rec.Exchange, rec.RoutingKey, rec.ContentType)
+	})
+}
+
+// isRPC reports whether a message is a request to one of the server's RPC
+// queues. They carry passwords and session tokens, and their replies go to
+// the address of the caller, so they are neither captured nor replayed.
+func isRPC(exchange, key string) bool {
+	if exchange == routing.ExchangeDefault {
+		return true
+	}
+	switch key {
+	case routing.JoinQueue, routing.AuthQueue, routing.RoomsQueue, routing.MatchmakingQueue:
+		return true
+	}
+	return false
+}
+
+// captureTaps binds to everything on the topic exchange and to every key the
+// given games route through the direct exchange. Direct exchanges have no
+// wildcards, so the game ID has to be filled in for each game. RPC requests
+// are never tapped, see isRPC.
+func captureTaps(games []string) []capture.Tap {
+	taps := []capture.Tap{{Exchange: routing.ExchangePerilTopic, Keys: []string{"#"}}}
+	direct := capture.Tap{Exchange: routing.ExchangePerilDirect}
+	seen := map[string]bool{}
+	add := func(key string) {
+		if !strings.Contains(key, routing.UsernamePlaceholder) && !isRPC(direct.Exchange, key) && !seen[key] {
+			seen[key] = true
+			direct.Keys = append(direct.Keys, key)
+		}
+	}
+	for _, q := range routing.ExpectedTopology().Queues {
+		for _, b := range q.Bindings {
+			if b.Exchange != routing.ExchangePerilDirect {
+				continue
+			}
+			if !strings.Contains(b.Key, routing.GamePlaceholder) {
+				add(b.Key)
+				continue
+			}
+			for _, game := range games {
+				add(strings.ReplaceAll(b.Key, routing.GamePlaceholder, game))
+			}
+		}
+	}
+	return append(taps, direct)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+var bodyDecoders = map[string]func(contentType string, body []byte) (any, error){
+	routing.ArmyMovesPrefix: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[gamelogic.ArmyMove](ct, b)
+	},
+	routing.WarResultsPrefix: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[gamelogic.WarResult](ct, b)
+	},
+	routing.PauseKey: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[routing.PlayingState](ct, b)
+	},
+	routing.TurnStartedKey: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[routing.TurnStarted](ct, b)
+	},
+	routing.TurnEndedKey: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[routing.TurnEnded](ct, b)
+	},
+	routing.GameOverKey: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[gamelogic.GameOver](ct, b)
+	},
+	routing.GameLogSlug: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[routing.GameLog](ct, b)
+	},
+	routing.SpawnOrdersPrefix: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[gamelogic.SpawnOrder](ct, b)
+	},
+	routing.MoveOrdersPrefix: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[gamelogic.MoveOrder](ct, b)
+	},
+	routing.StateDeltasPrefix: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[gamelogic.StateDelta](ct, b)
+	},
+	routing.DiplomacyPrefix: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[gamelogic.Diplomacy](ct, b)
+	},
+	routing.AlliancesKey: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[gamelogic.Diplomacy](ct, b)
+	},
+	routing.PresencePrefix: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[routing.Heartbeat](ct, b)
+	},
+	routing.RosterPrefix: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[routing.PresenceEvent](ct, b)
+	},
+	routing.KickPrefix: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[routing.Kick](ct, b)
+	},
+	routing.BanPrefix: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[routing.Ban](ct, b)
+	},
+	routing.MutePrefix: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[routing.Mute](ct, b)
+	},
+	routing.BroadcastKey: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[routing.Broadcast](ct, b)
+	},
+	routing.AnnounceKey: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[routing.Announcement](ct, b)
+	},
+	routing.MatchesPrefix: func(ct string, b []byte) (any, error) {
+		return pubsub.Unmarshal[gamelogic.MatchNotice](ct, b)
+	},
+}
+
+func runDLQ(url string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: dlq <list|show|replay|purge>")
+	}
+
+	conn, ch, err := dial(url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch args[0] {
+	case "list":
+		return pubsub.WalkDeadLetters(ch, func(dl pubsub.DeadLetter) pubsub.HandlerOutcome {
+			fmt.Printf("* %s: %s/%s from %s (%s, %s, %d bytes)\n",
+				dl.ID, dl.Exchange, dl.RoutingKey, dl.Queue, dl.Reason, dl.Delivery.ContentType, len(dl.Delivery.Body))
+			return pubsub.NackRequeue
+		})
+	case "show":
+		if len(args) < 2 {
+			return errors.New("usage: dlq show <id>")
+		}
+		found := false
+		err := pubsub.WalkDeadLetters(ch, func(dl pubsub.DeadLetter) pubsub.HandlerOutcome {
+			if dl.ID == args[1] {
+				found = true
+				printDeadLetter(dl)
+			}
+			return pubsub.NackRequeue
+		})
+		if err == nil && !found {
+			err = fmt.Errorf("message %s not found in %s", args[1], routing.DeadLetterQueue)
+		}
+		return err
+	case "replay":
+		replayed := 0
+		err := pubsub.WalkDeadLetters(ch, func(dl pubsub.DeadLetter) pubsub.HandlerOutcome {
+			if len(args) > 1 && dl.ID != args[1] {
+				return pubsub.NackRequeue
+			}
+			if err := pubsub.Republish(ch, dl); err != nil {
+				fmt.Printf("could not replay %s: %v\n", dl.ID, err)
+				return pubsub.NackRequeue
+			}
+			fmt.Printf("replayed %s to %s/%s\n", dl.ID, dl.Exchange, dl.RoutingKey)
+			replayed++
+			return pubsub.Ack
+		})
+		fmt.Printf("%d message(s) replayed\n", replayed)
+		return err
+	case "purge":
+		n, err := pubsub.PurgeDeadLetters(ch)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d message(s) purged from %s\n", n, routing.DeadLetterQueue)
+		return nil
+	default:
+		return fmt.Errorf("unknown dlq command %q", args[0])
+	}
+}
+
+func printDeadLetter(dl pubsub.DeadLetter) {
+	fmt.Printf("ID:           %s\n", dl.ID)
+	fmt.Printf("Exchange:     %s\n", dl.Exchange)
+	fmt.Printf("Routing key:  %s\n", dl.RoutingKey)
+	fmt.Printf("Queue:        %s\n", dl.Queue)
+	fmt.Printf("Reason:       %s\n", dl.Reason)
+	fmt.Printf("Content type: %s\n", dl.Delivery.ContentType)
+	if !dl.Delivery.Timestamp.IsZero() {
+		fmt.Printf("Published:    %v\n", dl.Delivery.Timestamp)
+	}
+
+	decode, ok := bodyDecoders[routing.Category(dl.RoutingKey)]
+	if !ok {
+		fmt.Printf("Body (raw):   %q\n", dl.Delivery.Body)
+		return
+	}
+	val, err := decode(dl.Delivery.ContentType, dl.Delivery.Body)
+	if err != nil {
+		fmt.Printf("Body (raw):   %q\n", dl.Delivery.Body)
+		fmt.Printf("could not decode body: %v\n", err)
+		return
+	}
+	pretty, err := json.MarshalIndent(val, "", "  ")
+	if err != nil {
+		fmt.Printf("Body:         %+v\n", val)
+		return
+	}
+	fmt.Printf("Body (%T):\n%s\n", val, pretty)
+}
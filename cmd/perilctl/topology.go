@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/mgmt"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func runTopology(apiURL string, args []string) error {
+	fs := flag.NewFlagSet("topology", flag.ContinueOnError)
+	diffOnly := fs.Bool("diff", false, "only print differences from the expected topology")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	client, err := mgmt.NewClient(apiURL)
+	if err != nil {
+		return err
+	}
+	snap, err := client.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	if !*diffOnly {
+		printSnapshot(snap)
+	}
+
+	issues := mgmt.Diff(routing.ExpectedTopology(), snap)
+	fmt.Println("Differences from expected topology:")
+	if len(issues) == 0 {
+		fmt.Println("  none")
+		return nil
+	}
+	for _, issue := range issues {
+		fmt.Printf("  [%s] %s: %s\n", issue.Kind, issue.Object, issue.Detail)
+	}
+	return errors.New("topology does not match")
+}
+
+func printSnapshot(snap mgmt.Snapshot) {
+	fmt.Println("Exchanges:")
+	for _, ex := range snap.Exchanges {
+		if ex.Name == "" {
+			continue
+		}
+		fmt.Printf("  * %s (%s, durable=%v)\n", ex.Name, ex.Type, ex.Durable)
+	}
+
+	fmt.Println("Queues:")
+	sort.Slice(snap.Queues, func(i, j int) bool {
+		return snap.Queues[i].Name < snap.Queues[j].Name
+	})
+	for _, q := range snap.Queues {
+		fmt.Printf("  * %s (durable=%v, consumers=%d, messages=%d)\n", q.Name, q.Durable, q.Consumers, q.Messages)
+	}
+
+	fmt.Println("Bindings:")
+	for _, b := range snap.Bindings {
+		if b.Source == "" {
+			continue
+		}
+		fmt.Printf("  * %s -[%s]-> %s\n", b.Source, b.RoutingKey, b.Destination)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/auth"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// admin carries out the operator's commands. Bans are persisted by the ban
+// list, mutes only last until the server restarts. Kicks and bans end the
+// player's session, so the server stops taking their messages whether or not
+// their client obeys. Every admin message is signed with the server's key.
+type admin struct {
+	ch       *amqp.Channel
+	rooms    *roomRegistry
+	sessions *auth.Service
+	bans     *auth.BanList
+	limit    *logLimiter
+	signed   pubsub.PublishOption
+	muted    map[string]bool
+	mu       *sync.Mutex
+}
+
+func newAdmin(
+	ch *amqp.Channel, rooms *roomRegistry, sessions *auth.Service, bans *auth.BanList, limit *logLimiter, key ed25519.PrivateKey,
+) *admin {
+	return &admin{
+		ch:       ch,
+		rooms:    rooms,
+		sessions: sessions,
+		bans:     bans,
+		limit:    limit,
+		signed:   signedByServer(key),
+		muted:    map[string]bool{},
+		mu:       &sync.Mutex{},
+	}
+}
+
+func (a *admin) kick(username, reason string) error {
+	a.sessions.Revoke(username)
+	a.signOff(username)
+	key := fmt.Sprintf("%s.%s", routing.KickPrefix, username)
+	return pubsub.PublishJSON(a.ch, routing.ExchangePerilDirect, key, routing.Kick{Username: username, Reason: reason}, a.signed)
+}
+
+func (a *admin) ban(username, reason string) error {
+	b, err := a.bans.Ban(username, reason)
+	if err != nil {
+		return err
+	}
+	a.sessions.Revoke(username)
+	a.signOff(username)
+	key := fmt.Sprintf("%s.%s", routing.BanPrefix, username)
+	return pubsub.PublishJSON(a.ch, routing.ExchangePerilDirect, key, b, a.signed)
+}
+
+func (a *admin) setMuted(username string, muted bool) error {
+	a.mu.Lock()
+	if muted {
+		a.muted[username] = true
+	} else {
+		delete(a.muted, username)
+		a.limit.forgive(username)
+	}
+	a.mu.Unlock()
+	key := fmt.Sprintf("%s.%s", routing.MutePrefix, username)
+	return pubsub.PublishJSON(a.ch, routing.ExchangePerilDirect, key, routing.Mute{Username: username, Muted: muted}, a.signed)
+}
+
+func (a *admin) isMuted(username string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.muted[username]
+}
+
+func (a *admin) broadcast(msg string) error {
+	return pubsub.PublishJSON(a.ch, routing.ExchangePerilDirect, routing.BroadcastKey, routing.Broadcast{Message: msg, SentAt: time.Now()}, a.signed)
+}
+
+func (a *admin) announce(game, msg string) error {
+	if _, ok := a.rooms.get(game); !ok {
+		return fmt.Errorf("no game named %s", game)
+	}
+	key := routing.GameKey(game, routing.AnnounceKey)
+	return pubsub.PublishJSON(a.ch, routing.ExchangePerilDirect, key, routing.Announcement{Game: game, Message: msg, SentAt: time.Now()}, a.signed)
+}
+
+// signOff marks the player offline in every room right away, rather than
+// waiting for their heartbeats to stop.
+func (a *admin) signOff(username string) {
+	for _, r := range a.rooms.list() {
+		r.roster.left(username, time.Now())
+	}
+}
+
+func printBans(bans []routing.Ban) {
+	if len(bans) == 0 {
+		fmt.Println("Nobody is banned.")
+		return
+	}
+	for _, b := range bans {
+		reason := b.Reason
+		if reason == "" {
+			reason = "no reason given"
+		}
+		fmt.Printf("* %s: banned %v (%s)\n", b.Username, b.BannedAt.Format(time.DateTime), reason)
+	}
+}
+
+// joinArgs joins the words of a free-text argument back together.
+func joinArgs(inputs []string, from int) string {
+	if from >= len(inputs) {
+		return ""
+	}
+	return strings.Join(inputs[from:], " ")
+}
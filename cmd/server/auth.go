@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/auth"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// messageMaxAge is how long after they were sent messages from players are
+// accepted, and about how long their IDs are remembered to turn replays away.
+const messageMaxAge = 2 * time.Minute
+
+// signingKey returns the secret sessions are signed with. Without one a random
+// key is used, and sessions do not survive a restart.
+func signingKey(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	log.Println("no session secret set, sessions will not survive a restart")
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// serverKey derives the key the server signs admin messages with from the
+// session secret, so players can check them with the same public key after a
+// restart.
+func serverKey(secret []byte) ed25519.PrivateKey {
+	seed := sha256.Sum256(append([]byte("peril-server-key:"), secret...))
+	return ed25519.NewKeyFromSeed(seed[:])
+}
+
+func signedByServer(key ed25519.PrivateKey) pubsub.PublishOption {
+	return pubsub.SignedBy(routing.ServerName, "", func(payload []byte) []byte {
+		return ed25519.Sign(key, payload)
+	})
+}
+
+func handlerAuth(svc *auth.Service, server ed25519.PublicKey) func(gamelogic.AuthRequest) gamelogic.AuthResponse {
+	return func(req gamelogic.AuthRequest) gamelogic.AuthResponse {
+		var token string
+		var claims auth.Claims
+		var err error
+		switch req.Action {
+		case gamelogic.AuthRegister:
+			token, claims, err = svc.Register(req.Username, req.Password)
+			if err == nil {
+				log.Printf("%s registered\n", claims.Username)
+			}
+		case gamelogic.AuthLogin:
+			token, claims, err = svc.Login(req.Username, req.Password)
+		case gamelogic.AuthVerify:
+			token = req.Token
+			claims, err = svc.Verify(req.Token)
+		default:
+			err = fmt.Errorf("unknown auth action %q", req.Action)
+		}
+		if err != nil {
+			return gamelogic.AuthResponse{Error: err.Error()}
+		}
+		keyID, key := svc.SessionKey(claims)
+		return gamelogic.AuthResponse{Username: claims.Username, Token: token, ExpiresAt: claims.ExpiresAt, KeyID: keyID, SessionKey: key, ServerKey: server}
+	}
+}
+
+// handlerMatchmaking only lets players with a valid session queue, under the
+// username of their session.
+func handlerMatchmaking(svc *auth.Service, mm *matchmaker) func(gamelogic.MatchRequest) gamelogic.MatchResponse {
+	return func(req gamelogic.MatchRequest) gamelogic.MatchResponse {
+		claims, err := svc.Verify(req.Token)
+		if err != nil {
+			return gamelogic.MatchResponse{Error: err.Error()}
+		}
+		req.Username = claims.Username
+		return mm.handle(req)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const clockResolution = 1 * time.Second
+
+// Turns only count down while the game is not paused.
+type gameClock struct {
+	ch              *amqp.Channel
+	game            string
+	world           *gamelogic.World
+	state           routing.PlayingState
+	turn            int
+	remaining       time.Duration
+	incomeRemaining time.Duration
+	elapsed         time.Duration
+	over            bool
+	record          func(routing.GameLog)
+	finished        func()
+	signed          pubsub.PublishOption
+	mu              *sync.Mutex
+}
+
+func newGameClock(
+	ch *amqp.Channel, game string, world *gamelogic.World, record func(routing.GameLog), finished func(), signed pubsub.PublishOption,
+) *gameClock {
+	return &gameClock{
+		ch:              ch,
+		game:            game,
+		world:           world,
+		incomeRemaining: world.Rules().IncomeInterval(),
+		record:          record,
+		finished:        finished,
+		signed:          signed,
+		mu:              &sync.Mutex{},
+	}
+}
+
+func (c *gameClock) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(clockResolution)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			c.tick()
+		}
+	}
+}
+
+func (c *gameClock) tick() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state.IsPaused || c.over {
+		return
+	}
+	c.elapsed += clockResolution
+	defer c.checkVictory()
+
+	if !c.state.TurnBased {
+		if err := c.publishResolution(c.world.Advance(clockResolution)); err != nil {
+			log.Printf("[%s] could not publish arrivals: %v\n", c.game, err)
+		}
+		c.incomeRemaining -= clockResolution
+		if c.incomeRemaining <= 0 {
+			c.incomeRemaining = c.world.Rules().IncomeInterval()
+			if err := c.payIncome(); err != nil {
+				log.Printf("[%s] could not pay income: %v\n", c.game, err)
+			}
+		}
+		return
+	}
+	c.remaining -= clockResolution
+	if c.remaining > 0 {
+		return
+	}
+	if err := c.endTurn(); err != nil {
+		log.Printf("[%s] could not end turn %d: %v\n", c.game, c.turn, err)
+	}
+	if err := c.startTurn(); err != nil {
+		log.Printf("[%s] could not start turn %d: %v\n", c.game, c.turn, err)
+	}
+}
+
+func (c *gameClock) snapshot() (routing.PlayingState, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state, c.turn
+}
+
+func (c *gameClock) isOver() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.over
+}
+
+func (c *gameClock) setPaused(paused bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.state.IsPaused = paused
+	return c.publishState()
+}
+
+// Orders still queued for the current turn are resolved before the mode
+// changes.
+func (c *gameClock) setTurnDuration(d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state.TurnBased {
+		if err := c.endTurn(); err != nil {
+			return err
+		}
+	}
+	c.state.TurnBased = d > 0
+	c.state.TurnDuration = d
+	if err := c.publishState(); err != nil {
+		return err
+	}
+	if c.state.TurnBased {
+		return c.startTurn()
+	}
+	c.turn = 0
+	return nil
+}
+
+// admit reports whether an order issued for turn should be queued for the end
+// of the current turn rather than applied immediately.
+func (c *gameClock) admit(turn int) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.over {
+		return false, errors.New("the game is over")
+	}
+	if !c.state.TurnBased {
+		if turn != 0 {
+			return false, fmt.Errorf("order for turn %d received, but the game is real-time", turn)
+		}
+		return false, nil
+	}
+	if turn != c.turn {
+		return false, fmt.Errorf("order for turn %d received during turn %d", turn, c.turn)
+	}
+	return true, nil
+}
+
+func (c *gameClock) publishState() error {
+	return pubsub.PublishJSON(c.ch, routing.ExchangePerilDirect, routing.GameKey(c.game, routing.PauseKey), c.state, c.signed)
+}
+
+func (c *gameClock) startTurn() error {
+	c.turn++
+	c.remaining = c.state.TurnDuration
+	log.Printf("[%s] turn %d started\n", c.game, c.turn)
+	return pubsub.PublishJSON(
+		c.ch, routing.ExchangePerilDirect, routing.GameKey(c.game, routing.TurnStartedKey), routing.TurnStarted{Turn: c.turn, Duration: c.state.TurnDuration}, c.signed,
+	)
+}
+
+func (c *gameClock) endTurn() error {
+	log.Printf("[%s] turn %d ended, resolving orders\n", c.game, c.turn)
+	err := pubsub.PublishJSON(c.ch, routing.ExchangePerilDirect, routing.GameKey(c.game, routing.TurnEndedKey), routing.TurnEnded{Turn: c.turn}, c.signed)
+	if err != nil {
+		return err
+	}
+
+	if err := c.publishResolution(c.world.ResolveTurn()); err != nil {
+		return err
+	}
+	return c.payIncome()
+}
+
+// Moves go out before the deltas and the wars they lead to.
+func (c *gameClock) publishResolution(res gamelogic.TurnResolution) error {
+	for _, mv := range res.Moves {
+		if err := publishMove(c.ch, c.game, c.world, mv, c.signed); err != nil {
+			return err
+		}
+	}
+	for _, delta := range res.Deltas {
+		if publishDelta(c.ch, c.game, delta, c.signed) != nil {
+			return fmt.Errorf("could not publish state of %s", delta.Username)
+		}
+	}
+	for _, wr := range res.Wars {
+		if err := publishWarResult(c.ch, c.game, wr, c.record, c.signed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *gameClock) checkVictory() {
+	over, ok := c.world.Rules().CheckVictory(c.world.GetPlayersSnap(), c.elapsed)
+	if !ok {
+		return
+	}
+	c.over = true
+	go c.finished()
+	over.EndedAt = time.Now()
+	log.Printf("[%s] game over: %s\n", c.game, over.Reason)
+	if err := pubsub.PublishJSON(c.ch, routing.ExchangePerilDirect, routing.GameKey(c.game, routing.GameOverKey), over, c.signed); err != nil {
+		log.Printf("could not publish game over: %v\n", err)
+	}
+
+	messages := []string{fmt.Sprintf("Game over: %s", over.Reason)}
+	for i, s := range over.Standings {
+		messages = append(messages, fmt.Sprintf("#%d %s: %d points (%d territories, %d units, power %d)", i+1, s.Username, s.Score, s.Territories, s.Units, s.Power))
+	}
+	for _, msg := range messages {
+		c.record(routing.GameLog{CurrentTime: over.EndedAt, Username: over.Winner, Message: msg, Game: c.game})
+	}
+}
+
+func (c *gameClock) payIncome() error {
+	for _, delta := range c.world.CollectIncome() {
+		if publishDelta(c.ch, c.game, delta, c.signed) != nil {
+			return fmt.Errorf("could not publish income of %s", delta.Username)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logstore"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+const defaultLogsLimit = 50
+
+var defaultLogPaths = map[string]string{
+	logstore.KindJSONL:  "game_logs.jsonl",
+	logstore.KindSQLite: "game_logs.db",
+}
+
+// parseLogFilter reads the arguments of the logs command. Arguments of the
+// form key=value set a filter field, the other words are the text to search.
+func parseLogFilter(args []string, now time.Time) (logstore.Filter, error) {
+	f := logstore.Filter{Limit: defaultLogsLimit}
+	text := []string{}
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			text = append(text, arg)
+			continue
+		}
+		var err error
+		switch key {
+		case "user":
+			f.Username = value
+		case "game":
+			f.Game = value
+		case "since":
+			f.Since, err = parseLogTime(value, now)
+		case "until":
+			f.Until, err = parseLogTime(value, now)
+		case "limit":
+			f.Limit, err = strconv.Atoi(value)
+		default:
+			text = append(text, arg)
+		}
+		if err != nil {
+			return logstore.Filter{}, fmt.Errorf("invalid %s %q: %v", key, value, err)
+		}
+	}
+	f.Text = strings.Join(text, " ")
+	return f, nil
+}
+
+// parseLogTime accepts an RFC 3339 time or a duration before now.
+func parseLogTime(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func printLogs(logs []routing.GameLog) {
+	if len(logs) == 0 {
+		fmt.Println("No game logs found.")
+		return
+	}
+	for _, gl := range logs {
+		game := gl.Game
+		if game == "" {
+			game = "-"
+		}
+		fmt.Printf("%v [%s] %s: %s\n", gl.CurrentTime.Format(time.RFC3339), game, gl.Username, gl.Message)
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	minMatchSize = 2
+	maxMatchSize = 8
+	// maxRulesets is the most rulesets players may be queued with at once,
+	// the server's own included.
+	maxRulesets = 16
+)
+
+// bucket groups the players waiting for the same kind of game.
+type bucket struct {
+	size      int
+	rulesHash string
+}
+
+type match struct {
+	id       string
+	bucket   bucket
+	players  []string
+	accepted map[string]bool
+	timer    *time.Timer
+}
+
+// matchmaker queues players by game size and ruleset. Once a bucket holds
+// enough players they are offered a match, which everyone has to accept
+// within the timeout. Players who did accept go back to the front of the
+// queue when someone does not show up.
+type matchmaker struct {
+	ch      *amqp.Channel
+	rooms   *roomRegistry
+	timeout time.Duration
+	queues  map[bucket][]string
+	rules   map[string]*gamelogic.Ruleset
+	waiting map[string]bucket
+	matches map[string]*match
+	matched map[string]*match
+	mu      *sync.Mutex
+}
+
+func newMatchmaker(ch *amqp.Channel, rooms *roomRegistry, timeout time.Duration) *matchmaker {
+	return &matchmaker{
+		ch:      ch,
+		rooms:   rooms,
+		timeout: timeout,
+		queues:  map[bucket][]string{},
+		rules:   map[string]*gamelogic.Ruleset{rooms.rules.Hash(): rooms.rules},
+		waiting: map[string]bucket{},
+		matches: map[string]*match{},
+		matched: map[string]*match{},
+		mu:      &sync.Mutex{},
+	}
+}
+
+func (mm *matchmaker) handle(req gamelogic.MatchRequest) gamelogic.MatchResponse {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	var err error
+	resp := gamelogic.MatchResponse{}
+	switch req.Action {
+	case gamelogic.MatchQueue:
+		resp.Position, err = mm.enqueue(req)
+	case gamelogic.MatchAccept:
+		err = mm.accept(req.Username)
+	case gamelogic.MatchCancel:
+		err = mm.cancel(req.Username)
+	default:
+		err = fmt.Errorf("unknown matchmaking action %q", req.Action)
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	return resp
+}
+
+func (mm *matchmaker) enqueue(req gamelogic.MatchRequest) (int, error) {
+	if req.Username == "" {
+		return 0, errors.New("a username is required")
+	}
+	if _, ok := mm.waiting[req.Username]; ok {
+		return 0, errors.New("you are already queued")
+	}
+	if _, ok := mm.matched[req.Username]; ok {
+		return 0, errors.New("you already have a match waiting for you")
+	}
+	if req.Size < minMatchSize || req.Size > maxMatchSize {
+		return 0, fmt.Errorf("games have %d to %d players", minMatchSize, maxMatchSize)
+	}
+	if _, ok := mm.rules[req.RulesHash]; !ok {
+		if req.Rules == nil {
+			return 0, errors.New("unknown rules, send them along")
+		}
+		if err := req.Rules.Validate(); err != nil {
+			return 0, err
+		}
+		if req.Rules.Hash() != req.RulesHash {
+			return 0, errors.New("the rules do not match their hash")
+		}
+		mm.pruneRules()
+		if len(mm.rules) >= maxRulesets {
+			return 0, errors.New("too many different rules are queued, try the server's rules")
+		}
+		mm.rules[req.RulesHash] = req.Rules
+	}
+
+	b := bucket{size: req.Size, rulesHash: req.RulesHash}
+	mm.queues[b] = append(mm.queues[b], req.Username)
+	mm.waiting[req.Username] = b
+	position := len(mm.queues[b])
+	log.Printf("%s queued for a %d player %q game (#%d)\n", req.Username, b.size, mm.rules[b.rulesHash].Name, position)
+	mm.offer(b)
+	return position, nil
+}
+
+// pruneRules forgets the rulesets nobody is queued or matched with any more,
+// except for the server's own.
+func (mm *matchmaker) pruneRules() {
+	used := map[string]bool{mm.rooms.rules.Hash(): true}
+	for b, usernames := range mm.queues {
+		if len(usernames) > 0 {
+			used[b.rulesHash] = true
+		}
+	}
+	for _, m := range mm.matches {
+		used[m.bucket.rulesHash] = true
+	}
+	for hash := range mm.rules {
+		if !used[hash] {
+			delete(mm.rules, hash)
+		}
+	}
+	for b, usernames := range mm.queues {
+		if len(usernames) == 0 {
+			delete(mm.queues, b)
+		}
+	}
+}
+
+// offer starts a match for the first players of b when there are enough.
+func (mm *matchmaker) offer(b bucket) {
+	if len(mm.queues[b]) < b.size {
+		return
+	}
+	m := &match{
+		id:       newMatchID(),
+		bucket:   b,
+		players:  append([]string{}, mm.queues[b][:b.size]...),
+		accepted: map[string]bool{},
+	}
+	mm.queues[b] = mm.queues[b][b.size:]
+	for _, username := range m.players {
+		delete(mm.waiting, username)
+		mm.matched[username] = m
+	}
+	mm.matches[m.id] = m
+	m.timer = time.AfterFunc(mm.timeout, func() {
+		mm.expire(m.id)
+	})
+
+	log.Printf("match %s found for %v\n", m.id, m.players)
+	mm.notify(m.players, gamelogic.MatchNotice{
+		Kind:     gamelogic.MatchFound,
+		MatchID:  m.id,
+		Players:  m.players,
+		Deadline: time.Now().Add(mm.timeout),
+	})
+}
+
+func (mm *matchmaker) accept(username string) error {
+	m, ok := mm.matched[username]
+	if !ok {
+		return errors.New("you have no match to accept")
+	}
+	m.accepted[username] = true
+	if len(m.accepted) < len(m.players) {
+		return nil
+	}
+
+	m.timer.Stop()
+	mm.close(m)
+	game := "match-" + m.id
+	if _, err := mm.rooms.create(game, mm.rules[m.bucket.rulesHash], m.players...); err != nil {
+		mm.notify(m.players, gamelogic.MatchNotice{Kind: gamelogic.MatchCancelled, MatchID: m.id, Reason: err.Error()})
+		return err
+	}
+	log.Printf("match %s accepted by everyone, game %s created\n", m.id, game)
+	mm.notify(m.players, gamelogic.MatchNotice{Kind: gamelogic.MatchReady, MatchID: m.id, Game: game, Players: m.players})
+	return nil
+}
+
+// cancel takes the player out of the queue, or out of their match, which is
+// then called off for everyone.
+func (mm *matchmaker) cancel(username string) error {
+	if b, ok := mm.waiting[username]; ok {
+		delete(mm.waiting, username)
+		mm.queues[b] = slices.DeleteFunc(mm.queues[b], func(u string) bool {
+			return u == username
+		})
+		return nil
+	}
+	m, ok := mm.matched[username]
+	if !ok {
+		return errors.New("you are not queued")
+	}
+	m.timer.Stop()
+	mm.callOff(m, []string{username}, fmt.Sprintf("%s declined the match", username))
+	return nil
+}
+
+func (mm *matchmaker) expire(id string) {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	m, ok := mm.matches[id]
+	if !ok {
+		return
+	}
+	noShows := []string{}
+	for _, username := range m.players {
+		if !m.accepted[username] {
+			noShows = append(noShows, username)
+		}
+	}
+	mm.callOff(m, noShows, fmt.Sprintf("%v did not accept in time", noShows))
+}
+
+// callOff cancels m, dropping the given players and putting the others back
+// at the front of the queue.
+func (mm *matchmaker) callOff(m *match, dropped []string, reason string) {
+	mm.close(m)
+	log.Printf("match %s called off: %s\n", m.id, reason)
+	mm.notify(m.players, gamelogic.MatchNotice{Kind: gamelogic.MatchCancelled, MatchID: m.id, Players: m.players, Reason: reason})
+
+	requeued := []string{}
+	for _, username := range m.players {
+		if !slices.Contains(dropped, username) {
+			requeued = append(requeued, username)
+			mm.waiting[username] = m.bucket
+		}
+	}
+	mm.queues[m.bucket] = append(requeued, mm.queues[m.bucket]...)
+	mm.offer(m.bucket)
+}
+
+func (mm *matchmaker) close(m *match) {
+	delete(mm.matches, m.id)
+	for _, username := range m.players {
+		delete(mm.matched, username)
+	}
+}
+
+func (mm *matchmaker) notify(players []string, notice gamelogic.MatchNotice) {
+	for _, username := range players {
+		key := fmt.Sprintf("%s.%s", routing.MatchesPrefix, username)
+		if err := pubsub.PublishJSON(mm.ch, routing.ExchangePerilDirect, key, notice, mm.rooms.signed); err != nil {
+			log.Printf("could not notify %s of match %s: %v\n", username, notice.MatchID, err)
+		}
+	}
+}
+
+// queued returns the number of players waiting in each bucket.
+func (mm *matchmaker) queued() map[string]int {
+	mm.mu.Lock()
+	defer mm.mu.Unlock()
+	counts := map[string]int{}
+	for b, usernames := range mm.queues {
+		if len(usernames) > 0 {
+			counts[fmt.Sprintf("%d players, %q rules", b.size, mm.rules[b.rulesHash].Name)] = len(usernames)
+		}
+	}
+	return counts
+}
+
+func printQueues(counts map[string]int) {
+	if len(counts) == 0 {
+		fmt.Println("Nobody is queued.")
+		return
+	}
+	buckets := []string{}
+	for b := range counts {
+		buckets = append(buckets, b)
+	}
+	sort.Strings(buckets)
+	for _, b := range buckets {
+		fmt.Printf("* %s: %d waiting\n", b, counts[b])
+	}
+}
+
+func newMatchID() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
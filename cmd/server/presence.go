@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const presenceTimeout = 3 * routing.HeartbeatInterval
+
+type presence struct {
+	Username string
+	Online   bool
+	LastSeen time.Time
+}
+
+type roster struct {
+	ch      *amqp.Channel
+	game    string
+	players map[string]*presence
+	signed  pubsub.PublishOption
+	mu      *sync.Mutex
+}
+
+func newRoster(ch *amqp.Channel, game string, signed pubsub.PublishOption) *roster {
+	return &roster{
+		ch:      ch,
+		game:    game,
+		signed:  signed,
+		players: map[string]*presence{},
+		mu:      &sync.Mutex{},
+	}
+}
+
+func (r *roster) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(clockResolution)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			r.sweep(now)
+		}
+	}
+}
+
+func (r *roster) seen(username string, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.players[username]
+	if !ok {
+		p = &presence{Username: username}
+		r.players[username] = p
+	}
+	if at.After(p.LastSeen) {
+		p.LastSeen = at
+	}
+	if !p.Online && time.Since(p.LastSeen) < presenceTimeout {
+		p.Online = true
+		r.broadcast(username, true, at)
+	}
+}
+
+func (r *roster) left(username string, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.players[username]
+	if !ok || !p.Online {
+		return
+	}
+	p.Online = false
+	p.LastSeen = at
+	r.broadcast(username, false, at)
+}
+
+func (r *roster) sweep(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.players {
+		if p.Online && now.Sub(p.LastSeen) >= presenceTimeout {
+			p.Online = false
+			log.Printf("[%s] %s stopped responding\n", r.game, p.Username)
+			r.broadcast(p.Username, false, now)
+		}
+	}
+}
+
+func (r *roster) list() []presence {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	players := []presence{}
+	for _, p := range r.players {
+		players = append(players, *p)
+	}
+	sort.Slice(players, func(i, j int) bool {
+		return players[i].Username < players[j].Username
+	})
+	return players
+}
+
+func (r *roster) broadcast(username string, online bool, at time.Time) {
+	key := routing.GameKey(r.game, routing.RosterPrefix, username)
+	event := routing.PresenceEvent{Username: username, Online: online, At: at}
+	if err := pubsub.PublishJSON(r.ch, routing.ExchangePerilTopic, key, event, r.signed); err != nil {
+		log.Printf("[%s] could not publish presence of %s: %v\n", r.game, username, err)
+	}
+}
+
+func handlerHeartbeat(r *room) func(string, routing.Heartbeat) pubsub.HandlerOutcome {
+	return func(sender string, hb routing.Heartbeat) pubsub.HandlerOutcome {
+		if !r.member(sender) {
+			return pubsub.NackDiscard
+		}
+		// The sender's clock is not trusted: a heartbeat dated in the future
+		// would keep a player online long after they left.
+		now := time.Now()
+		if hb.Leaving {
+			r.roster.left(sender, now)
+			return pubsub.Ack
+		}
+		r.roster.seen(sender, now)
+		return pubsub.Ack
+	}
+}
+
+func printPlayers(r *room) {
+	players := r.roster.list()
+	if len(players) == 0 {
+		fmt.Printf("Nobody has joined %s yet.\n", r.id)
+		return
+	}
+	for _, p := range players {
+		status := "online"
+		if !p.Online {
+			status = fmt.Sprintf("offline, last seen %v ago", time.Since(p.LastSeen).Round(time.Second))
+		}
+		units := 0
+		if snap, ok := r.world.GetPlayerSnap(p.Username); ok {
+			units = len(snap.Units)
+		}
+		fmt.Printf("* %s: %s, %d unit(s)\n", p.Username, status, units)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// abuseWindow is how long the dropped logs of a player are counted for.
+const abuseWindow = 1 * time.Minute
+
+// Metrics served on /debug/vars when the server is started with -metrics.
+var (
+	logsAccepted  = expvar.NewInt("game_logs_accepted")
+	logsMuted     = expvar.NewInt("game_logs_muted")
+	logsLimited   = expvar.NewInt("game_logs_rate_limited")
+	logsLimitedBy = expvar.NewMap("game_logs_rate_limited_by_user")
+	logsAutoMutes = expvar.NewInt("game_logs_auto_mutes")
+)
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// abuse counts the logs a player had dropped since the window started.
+type abuse struct {
+	since   time.Time
+	dropped int
+}
+
+// logLimiter gives every player a token bucket refilled at rate logs per
+// second and holding up to burst logs. A player who has more than strikes
+// logs dropped within a minute is reported as abusive.
+type logLimiter struct {
+	rate    float64
+	burst   float64
+	strikes int
+	buckets map[string]*tokenBucket
+	abuse   map[string]*abuse
+	pruned  time.Time
+	mu      *sync.Mutex
+}
+
+func newLogLimiter(rate float64, burst, strikes int) *logLimiter {
+	return &logLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		strikes: strikes,
+		buckets: map[string]*tokenBucket{},
+		abuse:   map[string]*abuse{},
+		mu:      &sync.Mutex{},
+	}
+}
+
+// allow reports whether a log from username sent at sent may be written, and
+// whether the player just crossed the abuse threshold. Logs are limited by the
+// time they were sent so a backed up queue does not let a flood through, but
+// that time is clamped to now and to the previous log to keep clients from
+// earning tokens by lying about it.
+func (l *logLimiter) allow(username string, sent, now time.Time) (ok, abusive bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if sent.After(now) {
+		sent = now
+	}
+	if now.Sub(l.pruned) > abuseWindow {
+		l.prune(now)
+	}
+	b, found := l.buckets[username]
+	if !found {
+		b = &tokenBucket{tokens: l.burst, last: sent}
+		l.buckets[username] = b
+	}
+	if sent.After(b.last) {
+		b.tokens = min(l.burst, b.tokens+sent.Sub(b.last).Seconds()*l.rate)
+		b.last = sent
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, false
+	}
+
+	a, found := l.abuse[username]
+	if !found || sent.Sub(a.since) > abuseWindow {
+		a = &abuse{since: sent}
+		l.abuse[username] = a
+	}
+	a.dropped++
+	if a.dropped <= l.strikes {
+		return false, false
+	}
+	delete(l.abuse, username)
+	return false, true
+}
+
+// prune forgets the buckets that have refilled and the abuse outside the
+// window, a player who comes back starts out the same without them.
+func (l *logLimiter) prune(now time.Time) {
+	for username, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, username)
+		}
+	}
+	for username, a := range l.abuse {
+		if now.Sub(a.since) > abuseWindow {
+			delete(l.abuse, username)
+		}
+	}
+	l.pruned = now
+}
+
+// forgive forgets the player's abuse, so they start with a full bucket once
+// unmuted.
+func (l *logLimiter) forgive(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.buckets, username)
+	delete(l.abuse, username)
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type room struct {
+	id       string
+	world    *gamelogic.World
+	clock    *gameClock
+	roster   *roster
+	reserved map[string]bool
+	signed   pubsub.PublishOption
+	stop     chan struct{}
+}
+
+// Finished games make room for new ones, running games do not.
+const maxRooms = 100
+
+func (r *room) admits(username string) bool {
+	return r.reserved == nil || r.reserved[username]
+}
+
+func (r *room) member(username string) bool {
+	return r.world.Joined(username)
+}
+
+func (r *room) info() gamelogic.RoomInfo {
+	state, turn := r.clock.snapshot()
+	return gamelogic.RoomInfo{
+		ID:        r.id,
+		Players:   len(r.world.GetPlayersSnap()),
+		Paused:    state.IsPaused,
+		TurnBased: state.TurnBased,
+		Turn:      turn,
+		Over:      r.clock.isOver(),
+	}
+}
+
+type roomRegistry struct {
+	conn    *amqp.Connection
+	ch      *amqp.Channel
+	rules   *gamelogic.Ruleset
+	players *pubsub.Authenticator
+	record  func(routing.GameLog)
+	signed  pubsub.PublishOption
+	rooms   map[string]*room
+	mu      *sync.Mutex
+}
+
+func newRoomRegistry(
+	conn *amqp.Connection, ch *amqp.Channel, rules *gamelogic.Ruleset, players *pubsub.Authenticator, record func(routing.GameLog),
+	signed pubsub.PublishOption,
+) *roomRegistry {
+	return &roomRegistry{
+		conn:    conn,
+		ch:      ch,
+		rules:   rules,
+		players: players,
+		record:  record,
+		signed:  signed,
+		rooms:   map[string]*room{},
+		mu:      &sync.Mutex{},
+	}
+}
+
+// A nil rules uses the server's rules, and players reserves the room when it
+// is not empty.
+func (rr *roomRegistry) create(id string, rules *gamelogic.Ruleset, players ...string) (*room, error) {
+	if err := routing.ValidateGameID(id); err != nil {
+		return nil, err
+	}
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	if _, ok := rr.rooms[id]; ok {
+		return nil, fmt.Errorf("game %s already exists", id)
+	}
+	if len(rr.rooms) >= maxRooms {
+		for old, r := range rr.rooms {
+			if r.clock.isOver() {
+				delete(rr.rooms, old)
+			}
+		}
+	}
+	if len(rr.rooms) >= maxRooms {
+		return nil, fmt.Errorf("the server is running %d games already, try again later", len(rr.rooms))
+	}
+
+	if rules == nil {
+		rules = rr.rules
+	}
+	world := gamelogic.NewWorld(rules)
+	r := &room{id: id, world: world, roster: newRoster(rr.ch, id, rr.signed), signed: rr.signed, stop: make(chan struct{})}
+	r.clock = newGameClock(rr.ch, id, world, rr.record, func() {
+		rr.finish(r)
+	}, rr.signed)
+	if len(players) > 0 {
+		r.reserved = map[string]bool{}
+		for _, username := range players {
+			r.reserved[username] = true
+		}
+	}
+
+	orders := pubsub.NewRouter(rr.conn, routing.GameKey(id, routing.OrdersQueue), pubsub.QueueDurable)
+	pubsub.HandleFrom(orders, routing.ExchangePerilTopic, routing.GameKey(id, routing.SpawnOrdersPrefix, "*"), rr.players, handlerSpawnOrder(rr.ch, r))
+	pubsub.HandleFrom(orders, routing.ExchangePerilTopic, routing.GameKey(id, routing.MoveOrdersPrefix, "*"), rr.players, handlerMoveOrder(rr.ch, r))
+	if err := orders.Run(); err != nil {
+		return nil, err
+	}
+
+	err := pubsub.SubscribeJSONFrom(
+		rr.conn,
+		routing.ExchangePerilTopic,
+		routing.GameKey(id, routing.DiplomacyPrefix),
+		routing.GameKey(id, routing.DiplomacyPrefix, "*"),
+		pubsub.QueueDurable,
+		rr.players,
+		handlerDiplomacy(rr.ch, r),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = pubsub.SubscribeJSONFrom(
+		rr.conn,
+		routing.ExchangePerilTopic,
+		routing.GameKey(id, routing.PresencePrefix),
+		routing.GameKey(id, routing.PresencePrefix, "*"),
+		pubsub.QueueDurable,
+		rr.players,
+		handlerHeartbeat(r),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	go r.clock.run(r.stop)
+	go r.roster.run(r.stop)
+	rr.rooms[id] = r
+	return r, nil
+}
+
+// The room stays listed until newer games need its place.
+func (rr *roomRegistry) finish(r *room) {
+	close(r.stop)
+	queues := []string{
+		routing.GameKey(r.id, routing.OrdersQueue),
+		routing.GameKey(r.id, routing.DiplomacyPrefix),
+		routing.GameKey(r.id, routing.PresencePrefix),
+	}
+	for _, name := range queues {
+		if _, err := rr.ch.QueueDelete(name, false, false, false); err != nil {
+			log.Printf("[%s] could not delete queue %s: %v\n", r.id, name, err)
+		}
+	}
+}
+
+func (rr *roomRegistry) get(id string) (*room, bool) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	r, ok := rr.rooms[id]
+	return r, ok
+}
+
+func (rr *roomRegistry) list() []*room {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rooms := []*room{}
+	for _, r := range rr.rooms {
+		rooms = append(rooms, r)
+	}
+	sort.Slice(rooms, func(i, j int) bool {
+		return rooms[i].id < rooms[j].id
+	})
+	return rooms
+}
+
+func (rr *roomRegistry) pick(id string) (*room, error) {
+	if id != "" {
+		r, ok := rr.get(id)
+		if !ok {
+			return nil, fmt.Errorf("no game named %s", id)
+		}
+		return r, nil
+	}
+	rooms := rr.list()
+	if len(rooms) != 1 {
+		return nil, fmt.Errorf("there are %d games, name the one you mean", len(rooms))
+	}
+	return rooms[0], nil
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+var ErrBanned = errors.New("you are banned from this server")
+
+// BanList keeps the banned players in a JSON file so bans survive restarts.
+type BanList struct {
+	path string
+	bans map[string]routing.Ban
+	mu   *sync.Mutex
+}
+
+func OpenBanList(path string) (*BanList, error) {
+	bl := &BanList{path: path, bans: map[string]routing.Ban{}, mu: &sync.Mutex{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return bl, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read bans: %v", err)
+	}
+	bans := []routing.Ban{}
+	if err := json.Unmarshal(data, &bans); err != nil {
+		return nil, fmt.Errorf("invalid bans file %s: %v", path, err)
+	}
+	for _, b := range bans {
+		bl.bans[b.Username] = b
+	}
+	return bl, nil
+}
+
+func (bl *BanList) Ban(username, reason string) (routing.Ban, error) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	b := routing.Ban{Username: username, Reason: reason, BannedAt: time.Now()}
+	bl.bans[username] = b
+	return b, bl.save()
+}
+
+func (bl *BanList) Unban(username string) error {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	if _, ok := bl.bans[username]; !ok {
+		return fmt.Errorf("%s is not banned", username)
+	}
+	delete(bl.bans, username)
+	return bl.save()
+}
+
+func (bl *BanList) IsBanned(username string) bool {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	_, ok := bl.bans[username]
+	return ok
+}
+
+func (bl *BanList) List() []routing.Ban {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	bans := []routing.Ban{}
+	for _, b := range bl.bans {
+		bans = append(bans, b)
+	}
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].Username < bans[j].Username
+	})
+	return bans
+}
+
+func (bl *BanList) save() error {
+	bans := []routing.Ban{}
+	for _, b := range bl.bans {
+		bans = append(bans, b)
+	}
+	data, err := json.MarshalIndent(bans, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := bl.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("could not save bans: %v", err)
+	}
+	return os.Rename(tmp, bl.path)
+}
@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrTooManyAttempts = errors.New("too many failed logins, try again later")
+
+// revoked replaces the current session of a player whose session was revoked,
+// it matches no session so they have to log in again.
+const revoked = "revoked"
+
+// After freeAttempts failed logins in a row a username is locked out for
+// lockout, doubling with every further failure up to maxLockout.
+const (
+	freeAttempts = 5
+	lockout      = 30 * time.Second
+	maxLockout   = 15 * time.Minute
+)
+
+// failures counts the failed logins of a username since its last success.
+type failures struct {
+	count int
+	last  time.Time
+	until time.Time
+}
+
+// Service logs players in and checks their sessions. Only the latest session
+// of each player is valid, so logging in again signs out other clients.
+// Banned players can neither log in nor use their sessions.
+type Service struct {
+	store    *Store
+	bans     *BanList
+	signer   *Signer
+	ttl      time.Duration
+	sessions map[string]string
+	failed   map[string]*failures
+	mu       *sync.Mutex
+}
+
+func NewService(store *Store, bans *BanList, signer *Signer, ttl time.Duration) *Service {
+	return &Service{
+		store:    store,
+		bans:     bans,
+		signer:   signer,
+		ttl:      ttl,
+		sessions: map[string]string{},
+		failed:   map[string]*failures{},
+		mu:       &sync.Mutex{},
+	}
+}
+
+func (s *Service) Register(username, password string) (string, Claims, error) {
+	if err := s.store.Register(username, password); err != nil {
+		return "", Claims{}, err
+	}
+	return s.issue(username)
+}
+
+// Login checks the password of a player, throttling usernames that failed
+// too many times in a row.
+func (s *Service) Login(username, password string) (string, Claims, error) {
+	now := time.Now()
+	if s.lockedOut(username, now) {
+		return "", Claims{}, ErrTooManyAttempts
+	}
+	if err := s.store.Authenticate(username, password); err != nil {
+		s.fail(username, now)
+		return "", Claims{}, err
+	}
+	s.succeed(username)
+	if s.bans.IsBanned(username) {
+		return "", Claims{}, ErrBanned
+	}
+	return s.issue(username)
+}
+
+// Verify returns the claims of a valid token. Tokens signed before a restart
+// stay valid until another login replaces their session.
+func (s *Service) Verify(token string) (Claims, error) {
+	c, err := s.signer.Verify(token, time.Now())
+	if err != nil {
+		return Claims{}, err
+	}
+	if s.bans.IsBanned(c.Username) {
+		return Claims{}, ErrBanned
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.sessions[c.Username]
+	if !ok {
+		s.sessions[c.Username] = c.Session
+		return c, nil
+	}
+	if current != c.Session {
+		return Claims{}, ErrInvalidToken
+	}
+	return c, nil
+}
+
+// SessionKey returns the key the player signs their messages with for the
+// session of c, and the ID they name it by. The ID carries the session and
+// its expiry, and only the server can derive a key for it.
+func (s *Service) SessionKey(c Claims) (string, []byte) {
+	keyID := c.Session + "." + strconv.FormatInt(c.ExpiresAt.Unix(), 10)
+	return keyID, s.signer.mac("msg:" + c.Username + ":" + keyID)
+}
+
+// VerifyMessage checks that sig was made over payload with the key of the
+// session keyID names, and that the session is current, unexpired and its
+// player not banned.
+func (s *Service) VerifyMessage(username, keyID string, payload, sig []byte) error {
+	session, expiry, ok := strings.Cut(keyID, ".")
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if !ok || err != nil || !time.Now().Before(time.Unix(unix, 0)) {
+		return ErrInvalidToken
+	}
+	h := hmac.New(sha256.New, s.signer.mac("msg:"+username+":"+keyID))
+	h.Write(payload)
+	if !hmac.Equal(sig, h.Sum(nil)) {
+		return ErrInvalidToken
+	}
+	if s.bans.IsBanned(username) {
+		return ErrBanned
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.sessions[username]
+	if !ok {
+		s.sessions[username] = session
+	} else if current != session {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// Revoke signs username out of their current session, for instance when they
+// are kicked.
+func (s *Service) Revoke(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[username] = revoked
+}
+
+func (s *Service) lockedOut(username string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.failed[username]
+	return ok && now.Before(f.until)
+}
+
+func (s *Service) fail(username string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, f := range s.failed {
+		if now.Sub(f.last) > maxLockout && now.After(f.until) {
+			delete(s.failed, name)
+		}
+	}
+	f, ok := s.failed[username]
+	if !ok {
+		f = &failures{}
+		s.failed[username] = f
+	}
+	f.count++
+	f.last = now
+	if f.count < freeAttempts {
+		return
+	}
+	wait := lockout
+	for i := freeAttempts; i < f.count && wait < maxLockout; i++ {
+		wait *= 2
+	}
+	f.until = now.Add(min(wait, maxLockout))
+}
+
+func (s *Service) succeed(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failed, username)
+}
+
+func (s *Service) issue(username string) (string, Claims, error) {
+	c := Claims{Username: username, Session: newSessionID(), ExpiresAt: time.Now().Add(s.ttl)}
+	token, err := s.signer.Sign(c)
+	if err != nil {
+		return "", Claims{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[username] = c.Session
+	return token, c, nil
+}
+
+func newSessionID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 8
+
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+type Account struct {
+	Username     string    `json:"username"`
+	PasswordHash []byte    `json:"password_hash"`
+	Created      time.Time `json:"created"`
+}
+
+// Store keeps the registered accounts in a JSON file, rewriting it on every
+// registration.
+type Store struct {
+	path     string
+	accounts map[string]Account
+	mu       *sync.Mutex
+}
+
+func OpenStore(path string) (*Store, error) {
+	s := &Store{path: path, accounts: map[string]Account{}, mu: &sync.Mutex{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read accounts: %v", err)
+	}
+	accounts := []Account{}
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("invalid accounts file %s: %v", path, err)
+	}
+	for _, a := range accounts {
+		s.accounts[a.Username] = a
+	}
+	return s, nil
+}
+
+func (s *Store) Register(username, password string) error {
+	if err := routing.ValidateUsername(username); err != nil {
+		return err
+	}
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("passwords must be at least %d characters long", minPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.accounts[username]; ok {
+		return fmt.Errorf("the username %s is taken", username)
+	}
+	s.accounts[username] = Account{Username: username, PasswordHash: hash, Created: time.Now()}
+	if err := s.save(); err != nil {
+		delete(s.accounts, username)
+		return err
+	}
+	return nil
+}
+
+func (s *Store) Authenticate(username, password string) error {
+	s.mu.Lock()
+	a, ok := s.accounts[username]
+	s.mu.Unlock()
+	if !ok {
+		return ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword(a.PasswordHash, []byte(password)) != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+func (s *Store) save() error {
+	accounts := []Account{}
+	for _, a := range s.accounts {
+		accounts = append(accounts, a)
+	}
+	data, err := json.MarshalIndent(accounts, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("could not save accounts: %v", err)
+	}
+	return os.Rename(tmp, s.path)
+}
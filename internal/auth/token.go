@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired session")
+
+type Claims struct {
+	Username  string    `json:"u"`
+	Session   string    `json:"s"`
+	ExpiresAt time.Time `json:"e"`
+}
+
+// Signer issues and verifies session tokens of the form payload.signature,
+// where the signature is an HMAC-SHA256 of the base64 encoded JSON claims.
+type Signer struct {
+	key []byte
+}
+
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+func (s *Signer) Sign(c Claims) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload)), nil
+}
+
+func (s *Signer) Verify(token string, now time.Time) (Claims, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return Claims{}, ErrInvalidToken
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.mac(payload)) {
+		return Claims{}, ErrInvalidToken
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	c := Claims{}
+	if err := json.Unmarshal(data, &c); err != nil || !now.Before(c.ExpiresAt) {
+		return Claims{}, ErrInvalidToken
+	}
+	return c, nil
+}
+
+func (s *Signer) mac(payload string) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
@@ -0,0 +1,125 @@
+package capture
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type Entry struct {
+	Time        time.Time `json:"time"`
+	Exchange    string    `json:"exchange"`
+	RoutingKey  string    `json:"routing_key"`
+	ContentType string    `json:"content_type"`
+	Priority    uint8     `json:"priority,omitempty"`
+	Body        []byte    `json:"body"`
+}
+
+type Tap struct {
+	Exchange string
+	Keys     []string
+}
+
+// Record binds an exclusive queue to every tap and writes each delivery to w
+// as a JSON line until ctx is cancelled. It returns the number of messages
+// captured. Requests expecting a reply are dropped, since their reply
+// address and correlation ID are not recorded and could not be replayed.
+func Record(ctx context.Context, ch *amqp.Channel, taps []Tap, w io.Writer) (int, error) {
+	queue, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		return 0, fmt.Errorf("could not declare tap queue: %v", err)
+	}
+	for _, tap := range taps {
+		for _, key := range tap.Keys {
+			if err := ch.QueueBind(queue.Name, key, tap.Exchange, false, nil); err != nil {
+				return 0, fmt.Errorf("could not bind tap to %s/%s: %v", tap.Exchange, key, err)
+			}
+		}
+	}
+
+	deliveryChan, err := ch.Consume(queue.Name, "", true, true, false, false, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	enc := json.NewEncoder(w)
+	count := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return count, nil
+		case m, ok := <-deliveryChan:
+			if !ok {
+				return count, nil
+			}
+			if m.ReplyTo != "" {
+				continue
+			}
+			rec := Entry{
+				Time:        time.Now(),
+				Exchange:    m.Exchange,
+				RoutingKey:  m.RoutingKey,
+				ContentType: m.ContentType,
+				Priority:    m.Priority,
+				Body:        m.Body,
+			}
+			if err := enc.Encode(rec); err != nil {
+				return count, fmt.Errorf("could not write capture: %v", err)
+			}
+			count++
+		}
+	}
+}
+
+func ReadAll(r io.Reader) ([]Entry, error) {
+	entries := []Entry{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Entry
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("invalid capture record on line %d: %v", line, err)
+		}
+		entries = append(entries, rec)
+	}
+	return entries, scanner.Err()
+}
+
+// Replay republishes entries to their original exchange and routing key,
+// preserving the gaps between them divided by speed. A speed of 0 replays as
+// fast as possible. Entries are published without a reply address or
+// correlation ID, so replayed requests get no answer, and without headers, so
+// messages players signed are replayed unsigned and the server rejects them.
+func Replay(ctx context.Context, ch *amqp.Channel, entries []Entry, speed float64, onPublish func(Entry)) error {
+	for i, rec := range entries {
+		if i > 0 && speed > 0 {
+			gap := time.Duration(float64(rec.Time.Sub(entries[i-1].Time)) / speed)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(gap):
+			}
+		}
+		err := ch.PublishWithContext(ctx, rec.Exchange, rec.RoutingKey, false, false, amqp.Publishing{
+			ContentType: rec.ContentType,
+			Priority:    rec.Priority,
+			Timestamp:   time.Now(),
+			Body:        rec.Body,
+		})
+		if err != nil {
+			return fmt.Errorf("could not replay entry %d: %v", i+1, err)
+		}
+		if onPublish != nil {
+			onPublish(rec)
+		}
+	}
+	return nil
+}
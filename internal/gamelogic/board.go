@@ -0,0 +1,131 @@
+package gamelogic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Edge struct {
+	From       Location
+	To         Location
+	TravelTime time.Duration
+}
+
+// Board is an undirected graph of locations. Units move along its edges, up
+// to the number of hops their rank allows.
+type Board struct {
+	adjacency map[Location]map[Location]time.Duration
+}
+
+func NewBoard(locations []Location, edges []Edge) *Board {
+	b := &Board{adjacency: map[Location]map[Location]time.Duration{}}
+	for _, loc := range locations {
+		b.adjacency[loc] = map[Location]time.Duration{}
+	}
+	for _, e := range edges {
+		b.adjacency[e.From][e.To] = e.TravelTime
+		b.adjacency[e.To][e.From] = e.TravelTime
+	}
+	return b
+}
+
+func (b *Board) HasLocation(loc Location) bool {
+	_, ok := b.adjacency[loc]
+	return ok
+}
+
+func (b *Board) Locations() []Location {
+	locations := []Location{}
+	for loc := range b.adjacency {
+		locations = append(locations, loc)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i] < locations[j]
+	})
+	return locations
+}
+
+func (b *Board) Neighbours(loc Location) []Location {
+	neighbours := []Location{}
+	for n := range b.adjacency[loc] {
+		neighbours = append(neighbours, n)
+	}
+	sort.Slice(neighbours, func(i, j int) bool {
+		return neighbours[i] < neighbours[j]
+	})
+	return neighbours
+}
+
+func (b *Board) TravelTime(from, to Location) time.Duration {
+	return b.adjacency[from][to]
+}
+
+// Path returns the route with the fewest hops from one location to another,
+// preferring the quickest route among equally short ones.
+func (b *Board) Path(from, to Location) ([]Location, time.Duration, bool) {
+	if !b.HasLocation(from) || !b.HasLocation(to) {
+		return nil, 0, false
+	}
+	type step struct {
+		hops int
+		time time.Duration
+		prev Location
+	}
+	best := map[Location]step{from: {}}
+	frontier := []Location{from}
+	for len(frontier) > 0 {
+		next := []Location{}
+		for _, loc := range frontier {
+			for _, n := range b.Neighbours(loc) {
+				candidate := step{hops: best[loc].hops + 1, time: best[loc].time + b.adjacency[loc][n], prev: loc}
+				current, seen := best[n]
+				if !seen {
+					next = append(next, n)
+				}
+				if !seen || (candidate.hops == current.hops && candidate.time < current.time) {
+					best[n] = candidate
+				}
+			}
+		}
+		frontier = next
+	}
+
+	if _, ok := best[to]; !ok {
+		return nil, 0, false
+	}
+	path := []Location{to}
+	for loc := to; loc != from; loc = best[loc].prev {
+		path = append([]Location{best[loc].prev}, path...)
+	}
+	return path, best[to].time, true
+}
+
+func (gs *GameState) CommandMap() {
+	board := gs.rules.Board()
+	units := map[Location][]Unit{}
+	for _, unit := range gs.GetPlayerSnap().Units {
+		units[unit.Location] = append(units[unit.Location], unit)
+	}
+
+	fmt.Println("==== Map ====")
+	for _, loc := range board.Locations() {
+		routes := []string{}
+		for _, n := range board.Neighbours(loc) {
+			routes = append(routes, fmt.Sprintf("%s (%v)", n, board.TravelTime(loc, n)))
+		}
+		fmt.Printf("[%s]\n", loc)
+		fmt.Printf("    routes: %s\n", strings.Join(routes, ", "))
+		sort.Slice(units[loc], func(i, j int) bool {
+			return units[loc][i].ID < units[loc][j].ID
+		})
+		for _, unit := range units[loc] {
+			fmt.Printf("    * %v: %v (%d hp)\n", unit.ID, unit.Rank, unit.HP)
+		}
+	}
+	fmt.Println("Movement ranges:")
+	for _, rank := range gs.rules.Ranks {
+		fmt.Printf("* %s: %d hop(s), %d power, %d hp\n", rank.Name, rank.Range, rank.Power, rank.HP)
+	}
+}
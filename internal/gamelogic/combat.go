@@ -0,0 +1,117 @@
+package gamelogic
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+type CombatRules struct {
+	Rounds int  `json:"rounds"`
+	Dice   bool `json:"dice"`
+}
+
+// combatant is a unit taking part in a battle along with its remaining hit
+// points.
+type combatant struct {
+	unit Unit
+	hp   int
+}
+
+// Battle runs the combat rounds between two stacks of units at loc. It is
+// deterministic for a given seed, so every client can replay the server's
+// resolution from the war result. It returns the surviving units of each side,
+// with their remaining hit points.
+func (r *Ruleset) Battle(attackers, defenders []Unit, loc Location, seed int64) (attackerSurvivors, defenderSurvivors []Unit) {
+	rng := rand.New(rand.NewSource(seed))
+	atk := r.combatants(attackers)
+	def := r.combatants(defenders)
+	defenseBonus := 1 + r.locations[loc].DefenseBonus
+
+	for round := 0; round < r.Combat.Rounds && alive(atk) && alive(def); round++ {
+		toDefenders := r.volley(atk, def, rng, 1/defenseBonus)
+		toAttackers := r.volley(def, atk, rng, defenseBonus)
+		applyDamage(def, toDefenders)
+		applyDamage(atk, toAttackers)
+	}
+	return survivors(atk), survivors(def)
+}
+
+func (r *Ruleset) combatants(units []Unit) []*combatant {
+	sorted := append([]Unit{}, units...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+	cs := []*combatant{}
+	for _, unit := range sorted {
+		hp := unit.HP
+		if hp <= 0 {
+			hp = r.ranks[unit.Rank].HP
+		}
+		cs = append(cs, &combatant{unit: unit, hp: hp})
+	}
+	return cs
+}
+
+// volley returns the damage each unit of targets takes from one round of
+// attacks by shooters. Without dice every shooter focuses the first target
+// still standing; with dice targets are picked at random and damage is scaled
+// by a d6 roll.
+func (r *Ruleset) volley(shooters, targets []*combatant, rng *rand.Rand, multiplier float64) []int {
+	damage := make([]int, len(targets))
+	for _, shooter := range shooters {
+		if shooter.hp <= 0 {
+			continue
+		}
+		standing := []int{}
+		for i, t := range targets {
+			if t.hp > 0 {
+				standing = append(standing, i)
+			}
+		}
+		if len(standing) == 0 {
+			break
+		}
+
+		target := standing[0]
+		factor := multiplier
+		if r.Combat.Dice {
+			target = standing[rng.Intn(len(standing))]
+			factor *= float64(rng.Intn(6)+1) / 3.5
+		}
+		rank := r.ranks[shooter.unit.Rank]
+		modifier, ok := rank.Modifiers[targets[target].unit.Rank]
+		if !ok {
+			modifier = 1
+		}
+		damage[target] += int(math.Round(float64(rank.Power) * modifier * factor))
+	}
+	return damage
+}
+
+func applyDamage(cs []*combatant, damage []int) {
+	for i, c := range cs {
+		c.hp -= damage[i]
+	}
+}
+
+func alive(cs []*combatant) bool {
+	for _, c := range cs {
+		if c.hp > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func survivors(cs []*combatant) []Unit {
+	units := []Unit{}
+	for _, c := range cs {
+		if c.hp > 0 {
+			unit := c.unit
+			unit.HP = c.hp
+			units = append(units, unit)
+		}
+	}
+	return units
+}
@@ -0,0 +1,241 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+type DiplomacyAction string
+
+const (
+	DiplomacyProposePeace  DiplomacyAction = "propose-peace"
+	DiplomacyAlly          DiplomacyAction = "ally"
+	DiplomacyBreakAlliance DiplomacyAction = "break-alliance"
+)
+
+// Diplomacy is broadcast to every player and the server, each of which applies
+// it to its own Alliances. A proposal is accepted by answering it with ally.
+type Diplomacy struct {
+	From   string
+	To     string
+	Action DiplomacyAction
+}
+
+type pair [2]string
+
+func newPair(a, b string) pair {
+	if b < a {
+		a, b = b, a
+	}
+	return pair{a, b}
+}
+
+// Alliances tracks pending peace proposals and the alliances they turned into.
+// It is not safe for concurrent use, its owner guards it.
+type Alliances struct {
+	proposals map[pair]string
+	allied    map[pair]bool
+}
+
+func NewAlliances() *Alliances {
+	return &Alliances{
+		proposals: map[pair]string{},
+		allied:    map[pair]bool{},
+	}
+}
+
+// Validate checks d against the current relations without applying it.
+func (a *Alliances) Validate(d Diplomacy) error {
+	if d.From == "" || d.To == "" {
+		return errors.New("error: diplomacy needs two players")
+	}
+	if d.From == d.To {
+		return errors.New("error: you can not negotiate with yourself")
+	}
+	p := newPair(d.From, d.To)
+	switch d.Action {
+	case DiplomacyProposePeace:
+		if a.allied[p] {
+			return fmt.Errorf("error: %s and %s are already allies", d.From, d.To)
+		}
+		if a.proposals[p] == d.From {
+			return fmt.Errorf("error: %s already proposed peace to %s", d.From, d.To)
+		}
+	case DiplomacyAlly:
+		if a.allied[p] {
+			return fmt.Errorf("error: %s and %s are already allies", d.From, d.To)
+		}
+		if a.proposals[p] != d.To {
+			return fmt.Errorf("error: %s has not proposed peace to %s", d.To, d.From)
+		}
+	case DiplomacyBreakAlliance:
+		if !a.allied[p] {
+			return fmt.Errorf("error: %s and %s are not allies", d.From, d.To)
+		}
+	default:
+		return fmt.Errorf("error: unknown diplomatic action %q", d.Action)
+	}
+	return nil
+}
+
+func (a *Alliances) Apply(d Diplomacy) error {
+	if err := a.Validate(d); err != nil {
+		return err
+	}
+	p := newPair(d.From, d.To)
+	switch d.Action {
+	case DiplomacyProposePeace:
+		// Crossing proposals are as good as an acceptance.
+		if a.proposals[p] == d.To {
+			delete(a.proposals, p)
+			a.allied[p] = true
+			return nil
+		}
+		a.proposals[p] = d.From
+	case DiplomacyAlly:
+		delete(a.proposals, p)
+		a.allied[p] = true
+	case DiplomacyBreakAlliance:
+		delete(a.allied, p)
+	}
+	return nil
+}
+
+// Allied reports whether two players are allies. A nil Alliances has none.
+func (a *Alliances) Allied(p1, p2 string) bool {
+	if a == nil {
+		return false
+	}
+	return a.allied[newPair(p1, p2)]
+}
+
+func (a *Alliances) Allies(username string) []string {
+	allies := []string{}
+	for p := range a.allied {
+		switch username {
+		case p[0]:
+			allies = append(allies, p[1])
+		case p[1]:
+			allies = append(allies, p[0])
+		}
+	}
+	sort.Strings(allies)
+	return allies
+}
+
+// ProposalsTo returns the players waiting for username to accept their peace
+// proposal.
+func (a *Alliances) ProposalsTo(username string) []string {
+	from := []string{}
+	for p, proposer := range a.proposals {
+		if (p[0] == username || p[1] == username) && proposer != username {
+			from = append(from, proposer)
+		}
+	}
+	sort.Strings(from)
+	return from
+}
+
+// Snapshot returns the diplomacy that rebuilds the current relations when
+// applied in order to an empty Alliances.
+func (a *Alliances) Snapshot() []Diplomacy {
+	log := []Diplomacy{}
+	for p, proposer := range a.proposals {
+		to := p[0]
+		if to == proposer {
+			to = p[1]
+		}
+		log = append(log, Diplomacy{From: proposer, To: to, Action: DiplomacyProposePeace})
+	}
+	for p := range a.allied {
+		log = append(log,
+			Diplomacy{From: p[0], To: p[1], Action: DiplomacyProposePeace},
+			Diplomacy{From: p[1], To: p[0], Action: DiplomacyAlly},
+		)
+	}
+	return log
+}
+
+// CommandDiplomacy validates a diplomacy command. Relations only change once
+// the server accepted it and passed it on, so every player applies it in the
+// same order.
+func (gs *GameState) CommandDiplomacy(words []string) (Diplomacy, error) {
+	if gs.isGameOver() {
+		return Diplomacy{}, errors.New("the game is over, there is nothing left to negotiate")
+	}
+	if len(words) != 2 {
+		return Diplomacy{}, fmt.Errorf("usage: %s <player>", words[0])
+	}
+	d := Diplomacy{From: gs.GetUsername(), To: words[1], Action: DiplomacyAction(words[0])}
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	if err := gs.alliances.Validate(d); err != nil {
+		return Diplomacy{}, err
+	}
+	return d, nil
+}
+
+func (gs *GameState) HandleDiplomacy(d Diplomacy) {
+	gs.mu.Lock()
+	err := gs.alliances.Apply(d)
+	gs.mu.Unlock()
+	if err != nil {
+		if d.From == gs.GetUsername() || d.To == gs.GetUsername() {
+			fmt.Printf("Ignoring diplomacy from %s: %v\n", d.From, err)
+		}
+		return
+	}
+
+	switch {
+	case d.To == gs.GetUsername() && d.Action == DiplomacyProposePeace:
+		if gs.isAllied(d.From) {
+			fmt.Printf("%s also proposed peace, you are now allies!\n", d.From)
+			return
+		}
+		fmt.Printf("%s proposes peace! Type 'ally %s' to accept.\n", d.From, d.From)
+	case d.To == gs.GetUsername() && d.Action == DiplomacyAlly:
+		fmt.Printf("%s accepted your peace proposal, you are now allies!\n", d.From)
+	case d.To == gs.GetUsername() && d.Action == DiplomacyBreakAlliance:
+		fmt.Printf("%s broke your alliance!\n", d.From)
+	case d.From == gs.GetUsername():
+		return
+	case d.Action == DiplomacyAlly:
+		fmt.Printf("%s and %s are now allies.\n", d.From, d.To)
+	case d.Action == DiplomacyBreakAlliance:
+		fmt.Printf("%s broke their alliance with %s.\n", d.From, d.To)
+	}
+}
+
+// SetAlliances replays the server's view of diplomacy when joining.
+func (gs *GameState) SetAlliances(log []Diplomacy) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.alliances = NewAlliances()
+	for _, d := range log {
+		gs.alliances.Apply(d)
+	}
+}
+
+func (gs *GameState) isAllied(username string) bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.alliances.Allied(gs.Player.Username, username)
+}
+
+func (w *World) Negotiate(d Diplomacy) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, username := range []string{d.From, d.To} {
+		if _, err := w.player(username); err != nil {
+			return err
+		}
+	}
+	return w.alliances.Apply(d)
+}
+
+func (w *World) Diplomacy() []Diplomacy {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.alliances.Snapshot()
+}
@@ -0,0 +1,118 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The methods in this file are the pure game rules. They never touch a
+// GameState or print anything, so the server can run them against its world
+// model and the client against its local state.
+
+func (r *Ruleset) ValidateLocation(loc Location) error {
+	if !r.board.HasLocation(loc) {
+		return fmt.Errorf("error: %s is not a valid location", loc)
+	}
+	return nil
+}
+
+func (r *Ruleset) ValidateRank(rank UnitRank) error {
+	if _, ok := r.ranks[rank]; !ok {
+		return fmt.Errorf("error: %s is not a valid unit", rank)
+	}
+	return nil
+}
+
+func (r *Ruleset) ValidateSpawn(p Player, unit Unit) error {
+	if err := r.ValidateLocation(unit.Location); err != nil {
+		return err
+	}
+	if err := r.ValidateRank(unit.Rank); err != nil {
+		return err
+	}
+	if unit.Owner != p.Username {
+		return fmt.Errorf("error: unit %s does not belong to %s", unit.Key(), p.Username)
+	}
+	if _, ok := p.Units[unit.ID]; ok {
+		return fmt.Errorf("error: %s already has a unit with ID %v", p.Username, unit.ID)
+	}
+	if unit.ID != p.NextUnitID {
+		return fmt.Errorf("error: the next unit of %s must have ID %v, not %v", p.Username, p.NextUnitID, unit.ID)
+	}
+	if hp := r.ranks[unit.Rank].HP; unit.HP != hp {
+		return fmt.Errorf("error: a new %s must have %d hit points, not %d", unit.Rank, hp, unit.HP)
+	}
+	if cost := r.ranks[unit.Rank].Cost; cost > p.Treasury {
+		return fmt.Errorf("error: a(n) %s costs %d, %s only has %d", unit.Rank, cost, p.Username, p.Treasury)
+	}
+	return nil
+}
+
+// ValidateMove checks that every unit can reach to within its rank's movement
+// range and returns how long the slowest of them takes to get there.
+func (r *Ruleset) ValidateMove(p Player, unitIDs []int, to Location) (time.Duration, error) {
+	if err := r.ValidateLocation(to); err != nil {
+		return 0, err
+	}
+	if len(unitIDs) == 0 {
+		return 0, errors.New("error: no units to move")
+	}
+	travelTime := time.Duration(0)
+	for _, id := range unitIDs {
+		unit, ok := p.Units[id]
+		if !ok {
+			return 0, fmt.Errorf("error: %s has no unit with ID %v", p.Username, id)
+		}
+		path, t, ok := r.board.Path(unit.Location, to)
+		if !ok {
+			return 0, fmt.Errorf("error: there is no route from %s to %s", unit.Location, to)
+		}
+		rank := r.ranks[unit.Rank]
+		if hops := len(path) - 1; hops > rank.Range {
+			return 0, fmt.Errorf("error: unit %v (%s) can move %d hop(s), %s is %d away", id, unit.Rank, rank.Range, to, hops)
+		}
+		travelTime = max(travelTime, t)
+	}
+	return travelTime, nil
+}
+
+// ValidateArmyMove checks a move received from a peer for units that do not
+// belong to the mover or that share an ID.
+func ValidateArmyMove(move ArmyMove) error {
+	seen := map[string]struct{}{}
+	for _, unit := range move.Units {
+		if unit.Owner != move.Player.Username {
+			return fmt.Errorf("unit %s does not belong to %s", unit.Key(), move.Player.Username)
+		}
+		if _, ok := seen[unit.Key()]; ok {
+			return fmt.Errorf("unit %s is listed more than once", unit.Key())
+		}
+		seen[unit.Key()] = struct{}{}
+	}
+	for id, unit := range move.Player.Units {
+		if unit.ID != id || unit.Owner != move.Player.Username {
+			return fmt.Errorf("unit %s is stored under ID %v", unit.Key(), id)
+		}
+	}
+	return nil
+}
+
+func heldLocations(p Player) map[Location]bool {
+	held := map[Location]bool{}
+	for _, unit := range p.Units {
+		held[unit.Location] = true
+	}
+	return held
+}
+
+// ApplyMove returns the units of p listed in unitIDs relocated to to.
+func ApplyMove(p Player, unitIDs []int, to Location) []Unit {
+	moved := []Unit{}
+	for _, id := range unitIDs {
+		unit := p.Units[id]
+		unit.Location = to
+		moved = append(moved, unit)
+	}
+	return moved
+}
@@ -0,0 +1,146 @@
+package gamelogic
+
+import (
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+type EventKind string
+
+const (
+	EventSpawn    EventKind = "spawn"
+	EventMove     EventKind = "move"
+	EventState    EventKind = "state"
+	EventWar      EventKind = "war"
+	EventPause    EventKind = "pause"
+	EventTurn     EventKind = "turn"
+	EventGameOver EventKind = "game-over"
+)
+
+// Event records a change of the client's GameState, only the field of its
+// kind is set. Every change goes through Apply, so replaying the recorded
+// events rebuilds the same state.
+type Event struct {
+	Seq     int
+	Time    time.Time
+	Kind    EventKind
+	Spawn   *SpawnOrder
+	Move    *MoveOrder
+	State   *StateDelta
+	War     *WarResult
+	Playing *routing.PlayingState
+	Turn    int
+}
+
+// Snapshot is the state projected from the events up to Seq.
+type Snapshot struct {
+	Seq       int
+	Player    Player
+	Paused    bool
+	TurnBased bool
+	Turn      int
+	GameOver  bool
+}
+
+// SetRecorder has every event passed to record once it is applied. Events are
+// applied and recorded one at a time, in the same order.
+func (gs *GameState) SetRecorder(record func(Event)) {
+	gs.recordMu.Lock()
+	defer gs.recordMu.Unlock()
+	gs.recorder = record
+}
+
+func (gs *GameState) record(e Event) {
+	gs.recordMu.Lock()
+	defer gs.recordMu.Unlock()
+	e.Time = time.Now()
+	gs.Apply(e)
+	if gs.recorder != nil {
+		gs.recorder(e)
+	}
+}
+
+// Apply is the projection of the events onto the state.
+func (gs *GameState) Apply(e Event) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	p := &gs.Player
+	switch e.Kind {
+	case EventSpawn:
+		unit := e.Spawn.Unit
+		p.NextUnitID = max(p.NextUnitID, unit.ID+1)
+		if e.Spawn.Turn != 0 {
+			return
+		}
+		p.Units[unit.ID] = unit
+		if rank, ok := gs.rules.Rank(unit.Rank); ok {
+			p.Treasury -= rank.Cost
+		}
+	case EventMove:
+		// Queued and travelling units move with the server's state delta.
+		if e.Move.Turn != 0 || e.Move.TravelTime > 0 {
+			return
+		}
+		for _, id := range e.Move.UnitIDs {
+			if unit, ok := p.Units[id]; ok {
+				unit.Location = e.Move.ToLocation
+				p.Units[id] = unit
+			}
+		}
+	case EventState:
+		if e.State.Username == p.Username {
+			gs.applyDeltaLocked(*e.State)
+		}
+	case EventWar:
+		for _, id := range e.War.Casualties[p.Username] {
+			delete(p.Units, id)
+		}
+		for _, unit := range e.War.Wounded[p.Username] {
+			p.Units[unit.ID] = unit
+		}
+	case EventPause:
+		gs.Paused = e.Playing.IsPaused
+		gs.TurnBased = e.Playing.TurnBased
+		if !gs.TurnBased {
+			gs.Turn = 0
+			gs.pendingOrders = nil
+		}
+	case EventTurn:
+		gs.Turn = e.Turn
+		gs.pendingOrders = nil
+	case EventGameOver:
+		gs.GameOver = true
+	}
+}
+
+func (gs *GameState) Snapshot(seq int) Snapshot {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return Snapshot{
+		Seq:       seq,
+		Player:    copyPlayer(gs.Player),
+		Paused:    gs.Paused,
+		TurnBased: gs.TurnBased,
+		Turn:      gs.Turn,
+		GameOver:  gs.GameOver,
+	}
+}
+
+// RebuildGameState projects the events recorded after snap, which may be nil,
+// onto a new state.
+func RebuildGameState(username string, rules *Ruleset, snap *Snapshot, events []Event) *GameState {
+	gs := NewGameState(username, rules)
+	seq := 0
+	if snap != nil {
+		seq = snap.Seq
+		gs.Player = copyPlayer(snap.Player)
+		gs.Paused, gs.TurnBased, gs.Turn, gs.GameOver = snap.Paused, snap.TurnBased, snap.Turn, snap.GameOver
+	}
+	for _, e := range events {
+		if e.Seq > seq {
+			gs.Apply(e)
+		}
+	}
+	return gs
+}
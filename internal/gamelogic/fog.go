@@ -0,0 +1,89 @@
+package gamelogic
+
+// VisibilityRules limit what players see of each other's armies. With fog of
+// war on, a player only sees units within Range hops of a location they hold.
+type VisibilityRules struct {
+	FogOfWar bool `json:"fog_of_war"`
+	Range    int  `json:"range"`
+}
+
+// Within returns every location at most hops away from one of the given
+// locations, the locations themselves included.
+func (b *Board) Within(from map[Location]bool, hops int) map[Location]bool {
+	seen := map[Location]bool{}
+	frontier := []Location{}
+	for loc := range from {
+		if b.HasLocation(loc) && !seen[loc] {
+			seen[loc] = true
+			frontier = append(frontier, loc)
+		}
+	}
+	for ; hops > 0 && len(frontier) > 0; hops-- {
+		next := []Location{}
+		for _, loc := range frontier {
+			for _, n := range b.Neighbours(loc) {
+				if !seen[n] {
+					seen[n] = true
+					next = append(next, n)
+				}
+			}
+		}
+		frontier = next
+	}
+	return seen
+}
+
+func (r *Ruleset) VisibleLocations(viewer Player) map[Location]bool {
+	if !r.Visibility.FogOfWar {
+		visible := map[Location]bool{}
+		for _, loc := range r.board.Locations() {
+			visible[loc] = true
+		}
+		return visible
+	}
+	return r.board.Within(heldLocations(viewer), r.Visibility.Range)
+}
+
+// ViewMove returns what viewer can see of mv. Allies share everything, anyone
+// else only sees moves into their visible locations and the mover's units
+// there. ok is false when the move is hidden from viewer altogether.
+func (r *Ruleset) ViewMove(mv ArmyMove, viewer Player, allied bool) (view ArmyMove, ok bool) {
+	if !r.Visibility.FogOfWar || allied || viewer.Username == mv.Player.Username {
+		return mv, true
+	}
+	visible := r.VisibleLocations(viewer)
+	if !visible[mv.ToLocation] {
+		return ArmyMove{}, false
+	}
+
+	view = ArmyMove{
+		Player:     Player{Username: mv.Player.Username, Units: map[int]Unit{}},
+		Units:      mv.Units,
+		ToLocation: mv.ToLocation,
+		TravelTime: mv.TravelTime,
+		Partial:    true,
+	}
+	for id, unit := range mv.Player.Units {
+		if visible[unit.Location] {
+			view.Player.Units[id] = unit
+		}
+	}
+	return view, true
+}
+
+// MoveViews returns the view of mv for every player who can see it, keyed by
+// the viewer's username.
+func (w *World) MoveViews(mv ArmyMove) map[string]ArmyMove {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	views := map[string]ArmyMove{mv.Player.Username: mv}
+	for username, p := range w.players {
+		if username == mv.Player.Username {
+			continue
+		}
+		if view, ok := w.rules.ViewMove(mv, *p, w.alliances.Allied(username, mv.Player.Username)); ok {
+			views[username] = view
+		}
+	}
+	return views
+}
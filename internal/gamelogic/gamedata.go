@@ -1,8 +1,17 @@
 package gamelogic
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
 type Player struct {
-	Username string
-	Units    map[int]Unit
+	Username   string
+	Units      map[int]Unit
+	NextUnitID int
+	Treasury   int
 }
 
 type UnitRank string
@@ -13,40 +22,191 @@ const (
 	RankArtillery = "artillery"
 )
 
+// Unit IDs are allocated per player and never reused, so Owner and ID together
+// identify a unit across the whole game.
 type Unit struct {
 	ID       int
+	Owner    string
 	Rank     UnitRank
 	Location Location
+	HP       int
+}
+
+func (u Unit) Key() string {
+	return fmt.Sprintf("%s#%d", u.Owner, u.ID)
 }
 
+// ArmyMove is published to each player that can see it. Partial is set when
+// fog of war hid some of the mover's units from the receiver.
 type ArmyMove struct {
 	Player     Player
 	Units      []Unit
 	ToLocation Location
+	TravelTime time.Duration
+	Partial    bool
 }
 
-type RecognitionOfWar struct {
-	Attacker Player
-	Defender Player
+type AuthAction string
+
+const (
+	AuthRegister AuthAction = "register"
+	AuthLogin    AuthAction = "login"
+	AuthVerify   AuthAction = "verify"
+)
+
+// AuthRequest registers or logs in a player, or checks the session token a
+// returning client saved.
+type AuthRequest struct {
+	Action   AuthAction
+	Username string
+	Password string
+	Token    string
 }
 
-type Location string
+// AuthResponse carries the session token and the key the player signs their
+// messages to the server with. It only ever travels on the server's private
+// queues. ServerKey is the public key the server signs admin messages with.
+type AuthResponse struct {
+	Username   string
+	Token      string
+	ExpiresAt  time.Time
+	KeyID      string
+	SessionKey []byte
+	ServerKey  []byte
+	Error      string
+}
+
+// Requests from the lobby carry the player's session token, the server takes
+// the username from it.
+type JoinRequest struct {
+	Game      string
+	Username  string
+	RulesHash string
+	Token     string
+}
 
-func getAllRanks() map[UnitRank]struct{} {
-	return map[UnitRank]struct{}{
-		RankInfantry:  {},
-		RankCavalry:   {},
-		RankArtillery: {},
-	}
+// JoinResponse carries the server's ruleset whenever the hash sent by the
+// client does not match it.
+type JoinResponse struct {
+	Accepted     bool
+	Reason       string
+	RulesHash    string
+	Rules        *Ruleset
+	PlayingState routing.PlayingState
+	Turn         int
+	State        StateDelta
+	Diplomacy    []Diplomacy
 }
 
-func getAllLocations() map[Location]struct{} {
-	return map[Location]struct{}{
-		"americas":   {},
-		"europe":     {},
-		"africa":     {},
-		"asia":       {},
-		"australia":  {},
-		"antarctica": {},
-	}
+// RoomsRequest lists the server's games, creating the game named Create first
+// when it is set.
+type RoomsRequest struct {
+	Create string
+	Token  string
 }
+
+type RoomsResponse struct {
+	Rooms []RoomInfo
+	Error string
+}
+
+type RoomInfo struct {
+	ID        string
+	Players   int
+	Paused    bool
+	TurnBased bool
+	Turn      int
+	Over      bool
+}
+
+type MatchAction string
+
+const (
+	MatchQueue  MatchAction = "queue"
+	MatchAccept MatchAction = "accept"
+	MatchCancel MatchAction = "cancel"
+)
+
+// MatchRequest asks the server's matchmaker to queue a player for a game of
+// Size players with the given rules, or to accept or leave a match.
+type MatchRequest struct {
+	Action    MatchAction
+	Username  string
+	Size      int
+	RulesHash string
+	Rules     *Ruleset
+	Token     string
+}
+
+type MatchResponse struct {
+	Position int
+	Error    string
+}
+
+type MatchNoticeKind string
+
+const (
+	MatchFound     MatchNoticeKind = "found"
+	MatchReady     MatchNoticeKind = "ready"
+	MatchCancelled MatchNoticeKind = "cancelled"
+)
+
+// MatchNotice is sent to every player of a match. A found match has to be
+// accepted by everyone before Deadline, then a ready notice names the game to
+// join.
+type MatchNotice struct {
+	Kind     MatchNoticeKind
+	MatchID  string
+	Game     string
+	Players  []string
+	Deadline time.Time
+	Reason   string
+}
+
+// Orders carry the turn they were issued for in turn-based mode, and 0 in
+// real-time mode.
+type SpawnOrder struct {
+	Username string
+	Unit     Unit
+	Turn     int
+}
+
+// MoveOrder moves units at the end of Turn in turn-based mode. In real-time
+// mode the units arrive after TravelTime, which the server works out itself.
+type MoveOrder struct {
+	Username   string
+	UnitIDs    []int
+	ToLocation Location
+	Turn       int
+	TravelTime time.Duration
+}
+
+// StateDelta is published by the server whenever it changes a player's units.
+// A full delta replaces the receiver's units with Upserted.
+type StateDelta struct {
+	Username   string
+	Full       bool
+	Upserted   []Unit
+	Removed    []int
+	NextUnitID int
+	Treasury   int
+	Rejected   string
+}
+
+// WarResult is computed once by the server and applied by every participant.
+// Winner and Loser are empty when the war ended in a draw. Seed drives the
+// combat dice so clients can replay the battle and get the same result.
+type WarResult struct {
+	Attacker      string
+	Defender      string
+	Location      Location
+	Seed          int64
+	AttackerUnits []Unit
+	DefenderUnits []Unit
+	Winner        string
+	Loser         string
+	Casualties    map[string][]int
+	Wounded       map[string][]Unit
+}
+
+type Location string
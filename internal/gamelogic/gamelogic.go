@@ -7,6 +7,8 @@ import (
 	"math/rand"
 	"os"
 	"strings"
+
+	"golang.org/x/term"
 )
 
 func PrintClientHelp() {
@@ -17,7 +19,12 @@ func PrintClientHelp() {
 	fmt.Println("* spawn <location> <rank>")
 	fmt.Println("    example:")
 	fmt.Println("    spawn europe infantry")
+	fmt.Println("* propose-peace <player>")
+	fmt.Println("* ally <player>")
+	fmt.Println("* break-alliance <player>")
 	fmt.Println("* status")
+	fmt.Println("* map")
+	fmt.Println("* history [n]")
 	fmt.Println("* spam <n>")
 	fmt.Println("    example:")
 	fmt.Println("    spam 5")
@@ -25,23 +32,69 @@ func PrintClientHelp() {
 	fmt.Println("* help")
 }
 
-func ClientWelcome() (string, error) {
+// ClientWelcome asks for the credentials to log in or register with.
+func ClientWelcome() (AuthRequest, error) {
 	fmt.Println("Welcome to the Peril client!")
-	fmt.Println("Please enter your username:")
+	fmt.Println("Type login or register:")
 	words := GetInput()
+	if len(words) == 0 || (words[0] != string(AuthLogin) && words[0] != string(AuthRegister)) {
+		return AuthRequest{}, errors.New("you must login or register. goodbye")
+	}
+	req := AuthRequest{Action: AuthAction(words[0])}
+
+	fmt.Println("Please enter your username:")
+	words = GetInput()
 	if len(words) == 0 {
-		return "", errors.New("you must enter a username. goodbye")
+		return AuthRequest{}, errors.New("you must enter a username. goodbye")
 	}
-	username := words[0]
-	fmt.Printf("Welcome, %s!\n", username)
-	PrintClientHelp()
-	return username, nil
+	req.Username = words[0]
+
+	fmt.Println("Please enter your password:")
+	password, err := GetPassword()
+	if err != nil {
+		return AuthRequest{}, err
+	}
+	req.Password = password
+	return req, nil
+}
+
+func PrintLobbyHelp() {
+	fmt.Println("Possible commands:")
+	fmt.Println("* games")
+	fmt.Println("* create <game>")
+	fmt.Println("* join <game>")
+	fmt.Println("* queue <players>")
+	fmt.Println("    example:")
+	fmt.Println("    queue 4")
+	fmt.Println("* accept")
+	fmt.Println("* cancel")
+	fmt.Println("* quit")
+	fmt.Println("* help")
 }
 
 func PrintServerHelp() {
 	fmt.Println("Possible commands:")
-	fmt.Println("* pause")
-	fmt.Println("* resume")
+	fmt.Println("* games")
+	fmt.Println("* queue")
+	fmt.Println("* players [game]")
+	fmt.Println("* create <game>")
+	fmt.Println("* pause [game]")
+	fmt.Println("* resume [game]")
+	fmt.Println("* turns [game] <seconds>")
+	fmt.Println("    example:")
+	fmt.Println("    turns europe-night 30")
+	fmt.Println("* turns [game] off")
+	fmt.Println("* kick <user> [reason]")
+	fmt.Println("* ban <user> [reason]")
+	fmt.Println("* unban <user>")
+	fmt.Println("* bans")
+	fmt.Println("* mute <user>")
+	fmt.Println("* unmute <user>")
+	fmt.Println("* broadcast <message>")
+	fmt.Println("* announce <game> <message>")
+	fmt.Println("* logs [user=<user>] [game=<game>] [since=<time>] [until=<time>] [limit=<n>] [text]")
+	fmt.Println("    times are RFC 3339 or durations ago, example:")
+	fmt.Println("    logs user=alice since=1h retreat")
 	fmt.Println("* quit")
 	fmt.Println("* help")
 }
@@ -58,6 +111,25 @@ func GetInput() []string {
 	return strings.Fields(line)
 }
 
+// GetPassword reads a line without echoing it when stdin is a terminal.
+func GetPassword() (string, error) {
+	fmt.Print("> ")
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		words := GetInput()
+		if len(words) == 0 {
+			return "", errors.New("you must enter a password. goodbye")
+		}
+		return words[0], nil
+	}
+	password, err := term.ReadPassword(fd)
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
+
 func GetMaliciousLog() string {
 	possibleLogs := []string{
 		"Never interrupt your enemy when he is making a mistake.",
@@ -83,10 +155,26 @@ func (gs *GameState) CommandStatus() {
 	} else {
 		fmt.Println("The game is not paused.")
 	}
+	if gs.isTurnBased() {
+		fmt.Printf("It is turn %d.\n", gs.currentTurn())
+		for _, order := range gs.getPendingOrders() {
+			fmt.Printf("* queued: %s\n", order)
+		}
+	}
 
 	p := gs.GetPlayerSnap()
 	fmt.Printf("You are %s, and you have %d units.\n", p.Username, len(p.Units))
+	fmt.Printf("Your treasury holds %d, and your territories earn %d every %v.\n", p.Treasury, gs.rules.Income(controlledBy(Territories([]Player{p}), p.Username)), gs.rules.IncomeInterval())
 	for _, unit := range p.Units {
-		fmt.Printf("* %v: %v, %v\n", unit.ID, unit.Location, unit.Rank)
+		fmt.Printf("* %v: %v, %v (%d hp)\n", unit.ID, unit.Location, unit.Rank, unit.HP)
+	}
+	gs.mu.RLock()
+	allies, proposals := gs.alliances.Allies(p.Username), gs.alliances.ProposalsTo(p.Username)
+	gs.mu.RUnlock()
+	if len(allies) > 0 {
+		fmt.Printf("Your allies: %s\n", strings.Join(allies, ", "))
+	}
+	if len(proposals) > 0 {
+		fmt.Printf("Peace proposals waiting for you: %s\n", strings.Join(proposals, ", "))
 	}
 }
@@ -5,76 +5,53 @@ import (
 )
 
 type GameState struct {
-	Player Player
-	Paused bool
-	mu     *sync.RWMutex
+	Player        Player
+	Paused        bool
+	TurnBased     bool
+	Turn          int
+	GameOver      bool
+	pendingOrders []string
+	alliances     *Alliances
+	rules         *Ruleset
+	recorder      func(Event)
+	recordMu      *sync.Mutex
+	mu            *sync.RWMutex
 }
 
-func NewGameState(username string) *GameState {
+func NewGameState(username string, rules *Ruleset) *GameState {
 	return &GameState{
 		Player: Player{
-			Username: username,
-			Units:    map[int]Unit{},
+			Username:   username,
+			Units:      map[int]Unit{},
+			NextUnitID: 1,
+			Treasury:   rules.StartingTreasury,
 		},
-		Paused: false,
-		mu:     &sync.RWMutex{},
+		Paused:    false,
+		alliances: NewAlliances(),
+		rules:     rules,
+		recordMu:  &sync.Mutex{},
+		mu:        &sync.RWMutex{},
 	}
 }
 
-func (gs *GameState) resumeGame() {
-	gs.mu.Lock()
-	defer gs.mu.Unlock()
-	gs.Paused = false
-}
-
-func (gs *GameState) pauseGame() {
-	gs.mu.Lock()
-	defer gs.mu.Unlock()
-	gs.Paused = true
-}
-
 func (gs *GameState) isPaused() bool {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
 	return gs.Paused
 }
 
-func (gs *GameState) addUnit(u Unit) {
-	gs.mu.Lock()
-	defer gs.mu.Unlock()
-	gs.Player.Units[u.ID] = u
-}
-
-func (gs *GameState) removeUnitsInLocation(loc Location) {
-	gs.mu.Lock()
-	defer gs.mu.Unlock()
-	for k, v := range gs.Player.Units {
-		if v.Location == loc {
-			delete(gs.Player.Units, k)
-		}
-	}
-}
-
-func (gs *GameState) UpdateUnit(u Unit) {
+func (gs *GameState) allocateUnitID() int {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
-	gs.Player.Units[u.ID] = u
+	id := gs.Player.NextUnitID
+	gs.Player.NextUnitID++
+	return id
 }
 
 func (gs *GameState) GetUsername() string {
 	return gs.Player.Username
 }
 
-func (gs *GameState) getUnitsSnap() []Unit {
-	gs.mu.RLock()
-	defer gs.mu.RUnlock()
-	Units := []Unit{}
-	for _, v := range gs.Player.Units {
-		Units = append(Units, v)
-	}
-	return Units
-}
-
 func (gs *GameState) GetUnit(id int) (Unit, bool) {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
@@ -85,12 +62,24 @@ func (gs *GameState) GetUnit(id int) (Unit, bool) {
 func (gs *GameState) GetPlayerSnap() Player {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
-	Units := map[int]Unit{}
-	for k, v := range gs.Player.Units {
-		Units[k] = v
+	return copyPlayer(gs.Player)
+}
+
+// applyDeltaLocked must be called with gs.mu held.
+func (gs *GameState) applyDeltaLocked(delta StateDelta) {
+	if delta.Full {
+		gs.Player.Units = map[int]Unit{}
+	}
+	for _, u := range delta.Upserted {
+		gs.Player.Units[u.ID] = u
+	}
+	for _, id := range delta.Removed {
+		delete(gs.Player.Units, id)
 	}
-	return Player{
-		Username: gs.Player.Username,
-		Units:    Units,
+	// The server only takes the unit ID it expects next, so a resync, sent
+	// when it rejected an order, rewinds the IDs given to rejected spawns.
+	if delta.Full || delta.NextUnitID > gs.Player.NextUnitID {
+		gs.Player.NextUnitID = delta.NextUnitID
 	}
+	gs.Player.Treasury = delta.Treasury
 }
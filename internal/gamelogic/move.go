@@ -12,6 +12,7 @@ const (
 	MoveOutcomeSamePlayer MoveOutcome = iota
 	MoveOutComeSafe
 	MoveOutcomeMakeWar
+	MoveOutcomeInvalid
 )
 
 func (gs *GameState) HandleMove(move ArmyMove) MoveOutcome {
@@ -20,7 +21,7 @@ func (gs *GameState) HandleMove(move ArmyMove) MoveOutcome {
 
 	fmt.Println()
 	fmt.Println("==== Move Detected ====")
-	fmt.Printf("%s is moving %v unit(s) to %s\n", move.Player.Username, len(move.Units), move.ToLocation)
+	fmt.Printf("%s moved %v unit(s) to %s\n", move.Player.Username, len(move.Units), move.ToLocation)
 	for _, unit := range move.Units {
 		fmt.Printf("* %v\n", unit.Rank)
 	}
@@ -29,16 +30,37 @@ func (gs *GameState) HandleMove(move ArmyMove) MoveOutcome {
 		return MoveOutcomeSamePlayer
 	}
 
-	overlappingLocation := getOverlappingLocation(player, move.Player)
+	if err := ValidateArmyMove(move); err != nil {
+		fmt.Printf("Ignoring invalid move from %s: %v\n", move.Player.Username, err)
+		return MoveOutcomeInvalid
+	}
+	if move.Partial {
+		fmt.Printf("The fog of war hides the rest of %s's army, you can see %d of their units.\n", move.Player.Username, len(move.Player.Units))
+	}
+
+	gs.mu.RLock()
+	overlappingLocation := getOverlappingLocation(player, move.Player, gs.alliances)
+	gs.mu.RUnlock()
+	if overlappingLocation == "" && gs.isAllied(move.Player.Username) {
+		if shared := getOverlappingLocation(player, move.Player, nil); shared != "" {
+			fmt.Printf("Your ally %s shares %s with you.\n", move.Player.Username, shared)
+			return MoveOutComeSafe
+		}
+	}
 	if overlappingLocation != "" {
-		fmt.Printf("You have units in %s! You are at war with %s!\n", overlappingLocation, move.Player.Username)
+		fmt.Printf("You have units in %s! The server has resolved the war with %s.\n", overlappingLocation, move.Player.Username)
 		return MoveOutcomeMakeWar
 	}
 	fmt.Printf("You are safe from %s's units.\n", move.Player.Username)
 	return MoveOutComeSafe
 }
 
-func getOverlappingLocation(p1 Player, p2 Player) Location {
+// getOverlappingLocation returns a location where the two players' units
+// meet, or "" when there is none or the players are allies.
+func getOverlappingLocation(p1 Player, p2 Player, alliances *Alliances) Location {
+	if alliances.Allied(p1.Username, p2.Username) {
+		return ""
+	}
 	for _, u1 := range p1.Units {
 		for _, u2 := range p2.Units {
 			if u1.Location == u2.Location {
@@ -49,42 +71,48 @@ func getOverlappingLocation(p1 Player, p2 Player) Location {
 	return ""
 }
 
-func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
+func (gs *GameState) CommandMove(words []string) (MoveOrder, error) {
+	if gs.isGameOver() {
+		return MoveOrder{}, errors.New("the game is over, you can not move units")
+	}
 	if gs.isPaused() {
-		return ArmyMove{}, errors.New("the game is paused, you can not move units")
+		return MoveOrder{}, errors.New("the game is paused, you can not move units")
 	}
 	if len(words) < 3 {
-		return ArmyMove{}, errors.New("usage: move <location> <unitID> <unitID> <unitID> etc")
+		return MoveOrder{}, errors.New("usage: move <location> <unitID> <unitID> <unitID> etc")
 	}
 	newLocation := Location(words[1])
-	locations := getAllLocations()
-	if _, ok := locations[newLocation]; !ok {
-		return ArmyMove{}, fmt.Errorf("error: %s is not a valid location", newLocation)
-	}
 	unitIDs := []int{}
 	for _, word := range words[2:] {
 		id := word
 		unitID, err := strconv.Atoi(id)
 		if err != nil {
-			return ArmyMove{}, fmt.Errorf("error: %s is not a valid unit ID", id)
+			return MoveOrder{}, fmt.Errorf("error: %s is not a valid unit ID", id)
 		}
 		unitIDs = append(unitIDs, unitID)
 	}
 
-	for _, unitID := range unitIDs {
-		unit, ok := gs.GetUnit(unitID)
-		if !ok {
-			return ArmyMove{}, fmt.Errorf("error: unit with ID %v not found", unitID)
-		}
-		unit.Location = newLocation
-		gs.UpdateUnit(unit)
+	player := gs.GetPlayerSnap()
+	travelTime, err := gs.rules.ValidateMove(player, unitIDs, newLocation)
+	if err != nil {
+		return MoveOrder{}, err
 	}
 
-	mv := ArmyMove{
+	order := MoveOrder{
+		Username:   player.Username,
+		UnitIDs:    unitIDs,
 		ToLocation: newLocation,
-		Units:      gs.getUnitsSnap(),
-		Player:     gs.GetPlayerSnap(),
 	}
-	fmt.Printf("Moved %v units to %s\n", len(mv.Units), mv.ToLocation)
-	return mv, nil
+	if gs.isTurnBased() {
+		order.Turn = gs.currentTurn()
+		gs.record(Event{Kind: EventMove, Move: &order})
+		gs.queueOrder(fmt.Sprintf("move %v to %s", unitIDs, newLocation))
+		fmt.Printf("Queued move of %v units to %s for turn %d\n", len(order.UnitIDs), order.ToLocation, order.Turn)
+		return order, nil
+	}
+
+	order.TravelTime = travelTime
+	gs.record(Event{Kind: EventMove, Move: &order})
+	fmt.Printf("Sent %v units to %s, arriving in %v\n", len(order.UnitIDs), order.ToLocation, travelTime)
+	return order, nil
 }
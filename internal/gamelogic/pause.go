@@ -11,9 +11,23 @@ func (gs *GameState) HandlePause(ps routing.PlayingState) {
 	fmt.Println()
 	if ps.IsPaused {
 		fmt.Println("==== Pause Detected ====")
-		gs.pauseGame()
 	} else {
 		fmt.Println("==== Resume Detected ====")
-		gs.resumeGame()
+	}
+	if ps.TurnBased != gs.isTurnBased() {
+		if ps.TurnBased {
+			fmt.Printf("The game is now turn-based, turns last %v.\n", ps.TurnDuration)
+		} else {
+			fmt.Println("The game is now real-time.")
+		}
+	}
+	gs.record(Event{Kind: EventPause, Playing: &ps})
+}
+
+// SetPlayingState applies the state received when joining without announcing it.
+func (gs *GameState) SetPlayingState(ps routing.PlayingState, turn int) {
+	gs.record(Event{Kind: EventPause, Playing: &ps})
+	if ps.TurnBased {
+		gs.record(Event{Kind: EventTurn, Turn: turn})
 	}
 }
@@ -0,0 +1,245 @@
+package gamelogic
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+//go:embed default_rules.json
+var defaultRules []byte
+
+// Modifiers scale a rank's power against specific enemy ranks.
+type RankRules struct {
+	Name      UnitRank             `json:"name"`
+	Power     int                  `json:"power"`
+	HP        int                  `json:"hp"`
+	Cost      int                  `json:"cost"`
+	Range     int                  `json:"range"`
+	Modifiers map[UnitRank]float64 `json:"modifiers,omitempty"`
+}
+
+// DefenseBonus reduces the damage defenders take in a location and increases
+// the damage they deal, e.g. 0.25 for a 25% bonus.
+type LocationRules struct {
+	Name         Location `json:"name"`
+	Income       int      `json:"income"`
+	DefenseBonus float64  `json:"defense_bonus,omitempty"`
+}
+
+// VictoryRules lists the win conditions, a zero value disables a condition.
+// A continent only counts towards Continents while the player holds it with
+// at least Garrison units. No condition is checked before two players have
+// enlisted units.
+type VictoryRules struct {
+	Continents       int  `json:"continents"`
+	Garrison         int  `json:"garrison,omitempty"`
+	Elimination      bool `json:"elimination"`
+	TimeLimitSeconds int  `json:"time_limit_seconds"`
+}
+
+type EdgeRules struct {
+	From          Location `json:"from"`
+	To            Location `json:"to"`
+	TravelSeconds int      `json:"travel_seconds"`
+}
+
+// Ruleset holds every tunable of the game. The server distributes its ruleset
+// to clients on join so that everyone plays by the same rules.
+type Ruleset struct {
+	Name                  string          `json:"name"`
+	StartingTreasury      int             `json:"starting_treasury"`
+	IncomeIntervalSeconds int             `json:"income_interval_seconds"`
+	Ranks                 []RankRules     `json:"ranks"`
+	Locations             []LocationRules `json:"locations"`
+	Edges                 []EdgeRules     `json:"edges"`
+	Victory               VictoryRules    `json:"victory"`
+	Combat                CombatRules     `json:"combat"`
+	Visibility            VisibilityRules `json:"visibility"`
+
+	ranks     map[UnitRank]RankRules
+	locations map[Location]LocationRules
+	board     *Board
+}
+
+func DefaultRuleset() *Ruleset {
+	rules, err := ParseRuleset(defaultRules)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default ruleset: %v", err))
+	}
+	return rules
+}
+
+// LoadRuleset reads a ruleset from a JSON file, or returns the default
+// ruleset when path is empty.
+func LoadRuleset(path string) (*Ruleset, error) {
+	if path == "" {
+		return DefaultRuleset(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read rules file: %v", err)
+	}
+	return ParseRuleset(data)
+}
+
+func ParseRuleset(data []byte) (*Ruleset, error) {
+	rules := &Ruleset{}
+	if err := json.Unmarshal(data, rules); err != nil {
+		return nil, fmt.Errorf("could not parse rules: %v", err)
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Validate checks the ruleset for consistency and prepares it for use. It
+// must be called on any ruleset that was not created by ParseRuleset.
+func (r *Ruleset) Validate() error {
+	if len(r.Ranks) == 0 {
+		return errors.New("invalid rules: no ranks defined")
+	}
+	if len(r.Locations) == 0 {
+		return errors.New("invalid rules: no locations defined")
+	}
+	if r.StartingTreasury < 0 {
+		return errors.New("invalid rules: negative starting treasury")
+	}
+	if r.IncomeIntervalSeconds < 1 {
+		return errors.New("invalid rules: income interval must be at least one second")
+	}
+
+	r.ranks = map[UnitRank]RankRules{}
+	for _, rank := range r.Ranks {
+		if rank.Name == "" {
+			return errors.New("invalid rules: rank without a name")
+		}
+		if _, ok := r.ranks[rank.Name]; ok {
+			return fmt.Errorf("invalid rules: rank %s defined twice", rank.Name)
+		}
+		if rank.Power < 0 || rank.Cost < 0 {
+			return fmt.Errorf("invalid rules: rank %s has a negative power or cost", rank.Name)
+		}
+		if rank.Range < 1 {
+			return fmt.Errorf("invalid rules: rank %s must be able to move at least one hop", rank.Name)
+		}
+		if rank.HP < 1 {
+			return fmt.Errorf("invalid rules: rank %s must have at least one hit point", rank.Name)
+		}
+		r.ranks[rank.Name] = rank
+	}
+	for _, rank := range r.Ranks {
+		for target, modifier := range rank.Modifiers {
+			if _, ok := r.ranks[target]; !ok {
+				return fmt.Errorf("invalid rules: rank %s has a modifier against unknown rank %s", rank.Name, target)
+			}
+			if modifier < 0 {
+				return fmt.Errorf("invalid rules: rank %s has a negative modifier against %s", rank.Name, target)
+			}
+		}
+	}
+
+	locations := []Location{}
+	r.locations = map[Location]LocationRules{}
+	for _, loc := range r.Locations {
+		if loc.Name == "" {
+			return errors.New("invalid rules: location without a name")
+		}
+		if _, ok := r.locations[loc.Name]; ok {
+			return fmt.Errorf("invalid rules: location %s defined twice", loc.Name)
+		}
+		if loc.Income < 0 {
+			return fmt.Errorf("invalid rules: location %s has a negative income", loc.Name)
+		}
+		if loc.DefenseBonus < 0 {
+			return fmt.Errorf("invalid rules: location %s has a negative defense bonus", loc.Name)
+		}
+		r.locations[loc.Name] = loc
+		locations = append(locations, loc.Name)
+	}
+
+	edges := []Edge{}
+	for _, e := range r.Edges {
+		_, fromOK := r.locations[e.From]
+		_, toOK := r.locations[e.To]
+		if !fromOK || !toOK {
+			return fmt.Errorf("invalid rules: edge %s-%s references an unknown location", e.From, e.To)
+		}
+		if e.From == e.To {
+			return fmt.Errorf("invalid rules: edge %s-%s is a loop", e.From, e.To)
+		}
+		if e.TravelSeconds < 0 {
+			return fmt.Errorf("invalid rules: edge %s-%s has a negative travel time", e.From, e.To)
+		}
+		edges = append(edges, Edge{From: e.From, To: e.To, TravelTime: time.Duration(e.TravelSeconds) * time.Second})
+	}
+	r.board = NewBoard(locations, edges)
+
+	if r.Victory.Continents < 0 || r.Victory.Continents > len(r.Locations) {
+		return fmt.Errorf("invalid rules: victory requires %d continents, there are %d", r.Victory.Continents, len(r.Locations))
+	}
+	if r.Victory.Garrison < 0 {
+		return errors.New("invalid rules: negative garrison")
+	}
+	if r.Victory.TimeLimitSeconds < 0 {
+		return errors.New("invalid rules: negative time limit")
+	}
+	if r.Combat.Rounds < 1 {
+		return errors.New("invalid rules: combat needs at least one round")
+	}
+	if r.Visibility.Range < 0 {
+		return errors.New("invalid rules: negative visibility range")
+	}
+	return nil
+}
+
+// Hash identifies the ruleset so clients can check they run the same rules as
+// the server.
+func (r *Ruleset) Hash() string {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *Ruleset) Board() *Board {
+	return r.board
+}
+
+func (r *Ruleset) Rank(rank UnitRank) (RankRules, bool) {
+	rr, ok := r.ranks[rank]
+	return rr, ok
+}
+
+func (r *Ruleset) IncomeInterval() time.Duration {
+	return time.Duration(r.IncomeIntervalSeconds) * time.Second
+}
+
+func (r *Ruleset) TimeLimit() time.Duration {
+	return time.Duration(r.Victory.TimeLimitSeconds) * time.Second
+}
+
+// Income is what a player earns per income tick for the given territories.
+func (r *Ruleset) Income(territories []Location) int {
+	income := 0
+	for _, loc := range territories {
+		income += r.locations[loc].Income
+	}
+	return income
+}
+
+func (r *Ruleset) Power(units []Unit) int {
+	power := 0
+	for _, unit := range units {
+		power += r.ranks[unit.Rank].Power
+	}
+	return power
+}
@@ -5,30 +5,42 @@ import (
 	"fmt"
 )
 
-func (gs *GameState) CommandSpawn(words []string) error {
+func (gs *GameState) CommandSpawn(words []string) (SpawnOrder, error) {
+	if gs.isGameOver() {
+		return SpawnOrder{}, errors.New("the game is over, you can not spawn units")
+	}
 	if len(words) < 3 {
-		return errors.New("usage: spawn <location> <rank>")
+		return SpawnOrder{}, errors.New("usage: spawn <location> <rank>")
 	}
 
-	locationName := words[1]
-	locations := getAllLocations()
-	if _, ok := locations[Location(locationName)]; !ok {
-		return fmt.Errorf("error: %s is not a valid location", locationName)
+	unit := Unit{
+		Owner:    gs.GetUsername(),
+		Rank:     UnitRank(words[2]),
+		Location: Location(words[1]),
 	}
-
-	rank := words[2]
-	units := getAllRanks()
-	if _, ok := units[UnitRank(rank)]; !ok {
-		return fmt.Errorf("error: %s is not a valid unit", rank)
+	if err := gs.rules.ValidateLocation(unit.Location); err != nil {
+		return SpawnOrder{}, err
+	}
+	if err := gs.rules.ValidateRank(unit.Rank); err != nil {
+		return SpawnOrder{}, err
 	}
+	rank, _ := gs.rules.Rank(unit.Rank)
+	unit.HP = rank.HP
+	if treasury := gs.GetPlayerSnap().Treasury; rank.Cost > treasury {
+		return SpawnOrder{}, fmt.Errorf("error: a(n) %s costs %d, you only have %d", unit.Rank, rank.Cost, treasury)
+	}
+	unit.ID = gs.allocateUnitID()
+	order := SpawnOrder{Username: gs.GetUsername(), Unit: unit}
 
-	id := len(gs.getUnitsSnap()) + 1
-	gs.addUnit(Unit{
-		ID:       id,
-		Rank:     UnitRank(rank),
-		Location: Location(locationName),
-	})
+	if gs.isTurnBased() {
+		order.Turn = gs.currentTurn()
+		gs.record(Event{Kind: EventSpawn, Spawn: &order})
+		gs.queueOrder(fmt.Sprintf("spawn a(n) %s in %s with id %v", unit.Rank, unit.Location, unit.ID))
+		fmt.Printf("Queued spawn of a(n) %s in %s for turn %d\n", unit.Rank, unit.Location, order.Turn)
+		return order, nil
+	}
 
-	fmt.Printf("Spawned a(n) %s in %s with id %v\n", rank, locationName, id)
-	return nil
+	gs.record(Event{Kind: EventSpawn, Spawn: &order})
+	fmt.Printf("Spawned a(n) %s in %s with id %v\n", unit.Rank, unit.Location, unit.ID)
+	return order, nil
 }
@@ -0,0 +1,18 @@
+package gamelogic
+
+import (
+	"fmt"
+)
+
+func (gs *GameState) HandleStateDelta(delta StateDelta) {
+	if delta.Username != gs.GetUsername() {
+		return
+	}
+	if delta.Rejected != "" {
+		defer fmt.Println("------------------------")
+		fmt.Println()
+		fmt.Println("==== Order Rejected ====")
+		fmt.Printf("The server rejected your order: %s\n", delta.Rejected)
+	}
+	gs.record(Event{Kind: EventState, State: &delta})
+}
@@ -0,0 +1,167 @@
+package gamelogic
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type Standing struct {
+	Username    string
+	Territories int
+	Units       int
+	Power       int
+	Score       int
+}
+
+// GameOver is broadcast by the server once a win condition is met. Standings
+// are sorted from first to last place.
+type GameOver struct {
+	Winner    string
+	Reason    string
+	Standings []Standing
+	EndedAt   time.Time
+}
+
+// Territories maps every controlled location to its owner. A player controls
+// a location when they are the only one with units in it.
+func Territories(players []Player) map[Location]string {
+	owners := map[Location]string{}
+	contested := map[Location]bool{}
+	for _, p := range players {
+		for loc := range heldLocations(p) {
+			if owner, ok := owners[loc]; ok && owner != p.Username {
+				contested[loc] = true
+				continue
+			}
+			owners[loc] = p.Username
+		}
+	}
+	for loc := range contested {
+		delete(owners, loc)
+	}
+	return owners
+}
+
+func controlledBy(territories map[Location]string, username string) []Location {
+	locations := []Location{}
+	for loc, owner := range territories {
+		if owner == username {
+			locations = append(locations, loc)
+		}
+	}
+	return locations
+}
+
+// garrisoned counts the territories p controls with at least garrison of
+// their units in them.
+func garrisoned(territories map[Location]string, p Player, garrison int) int {
+	units := map[Location]int{}
+	for _, unit := range p.Units {
+		units[unit.Location]++
+	}
+	held := 0
+	for _, loc := range controlledBy(territories, p.Username) {
+		if units[loc] >= garrison {
+			held++
+		}
+	}
+	return held
+}
+
+func (r *Ruleset) Standings(players []Player) []Standing {
+	territories := Territories(players)
+	standings := []Standing{}
+	for _, p := range players {
+		units := []Unit{}
+		for _, unit := range p.Units {
+			units = append(units, unit)
+		}
+		s := Standing{
+			Username:    p.Username,
+			Territories: len(controlledBy(territories, p.Username)),
+			Units:       len(units),
+			Power:       r.Power(units),
+		}
+		s.Score = s.Territories*10 + s.Power
+		standings = append(standings, s)
+	}
+	sort.Slice(standings, func(i, j int) bool {
+		if standings[i].Score != standings[j].Score {
+			return standings[i].Score > standings[j].Score
+		}
+		return standings[i].Username < standings[j].Username
+	})
+	return standings
+}
+
+// CheckVictory reports whether any configured win condition is met after
+// elapsed time of play. A game needs two players who enlisted units, so a
+// lone player can not win it.
+func (r *Ruleset) CheckVictory(players []Player, elapsed time.Duration) (GameOver, bool) {
+	enlisted, alive := 0, []string{}
+	for _, p := range players {
+		if p.NextUnitID > 1 {
+			enlisted++
+		}
+		if len(p.Units) > 0 {
+			alive = append(alive, p.Username)
+		}
+	}
+	if enlisted < 2 {
+		return GameOver{}, false
+	}
+
+	standings := r.Standings(players)
+	over := GameOver{Standings: standings}
+
+	if r.Victory.Continents > 0 {
+		territories := Territories(players)
+		for _, p := range players {
+			if held := garrisoned(territories, p, r.Victory.Garrison); held >= r.Victory.Continents {
+				over.Winner = p.Username
+				over.Reason = fmt.Sprintf("%s holds %d continents", p.Username, held)
+				return over, true
+			}
+		}
+	}
+
+	if r.Victory.Elimination {
+		if len(alive) == 1 {
+			over.Winner = alive[0]
+			over.Reason = fmt.Sprintf("%s eliminated all opponents", alive[0])
+			return over, true
+		}
+	}
+
+	if limit := r.TimeLimit(); limit > 0 && elapsed >= limit && len(standings) > 0 {
+		over.Winner = standings[0].Username
+		over.Reason = fmt.Sprintf("%s has the highest score after %v", over.Winner, limit)
+		return over, true
+	}
+
+	return GameOver{}, false
+}
+
+func (gs *GameState) isGameOver() bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.GameOver
+}
+
+func (gs *GameState) HandleGameOver(over GameOver) {
+	defer fmt.Println("------------------------")
+	fmt.Println()
+	fmt.Println("==== Game Over ====")
+	fmt.Println(over.Reason)
+	if over.Winner == gs.GetUsername() {
+		fmt.Println("You have won the game!")
+	} else {
+		fmt.Printf("%s has won the game.\n", over.Winner)
+	}
+	fmt.Println("Final standings:")
+	for i, s := range over.Standings {
+		fmt.Printf("%d. %s: %d points (%d territories, %d units, power %d)\n", i+1, s.Username, s.Score, s.Territories, s.Units, s.Power)
+	}
+	gs.record(Event{Kind: EventGameOver})
+}
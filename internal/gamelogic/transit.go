@@ -0,0 +1,103 @@
+package gamelogic
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// transit is a real-time move whose units are on their way. They stay where
+// they left from, and can be fought there, until they arrive.
+type transit struct {
+	order     MoveOrder
+	remaining time.Duration
+}
+
+// Move sends units on their way in real-time mode and returns how long they
+// take to arrive. They arrive once that much game time has passed, see
+// Advance.
+func (w *World) Move(order MoveOrder) (time.Duration, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	p, err := w.player(order.Username)
+	if err != nil {
+		return 0, err
+	}
+	if err := w.checkNotTravelling(order); err != nil {
+		return 0, err
+	}
+	travelTime, err := w.rules.ValidateMove(*p, order.UnitIDs, order.ToLocation)
+	if err != nil {
+		return 0, err
+	}
+	w.transits = append(w.transits, transit{order: order, remaining: travelTime})
+	return travelTime, nil
+}
+
+// Advance lets d of game time pass for the units on their way, and returns
+// the moves of those that arrived and the wars they started.
+func (w *World) Advance(d time.Duration) TurnResolution {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	res := TurnResolution{}
+	w.advance(d, &res)
+	return res
+}
+
+func (w *World) advance(d time.Duration, res *TurnResolution) {
+	travelling := []transit{}
+	movedInto := map[string]map[Location]bool{}
+	for _, t := range w.transits {
+		t.remaining -= d
+		if t.remaining > 0 {
+			travelling = append(travelling, t)
+			continue
+		}
+		p, ok := w.players[t.order.Username]
+		if !ok {
+			continue
+		}
+		// Units lost in a war on the way do not arrive.
+		survivors := []int{}
+		for _, id := range t.order.UnitIDs {
+			if _, ok := p.Units[id]; ok {
+				survivors = append(survivors, id)
+			}
+		}
+		if len(survivors) == 0 {
+			continue
+		}
+		mv, delta := w.place(p, survivors, t.order.ToLocation)
+		res.Moves = append(res.Moves, mv)
+		res.Deltas = append(res.Deltas, delta)
+		if movedInto[p.Username] == nil {
+			movedInto[p.Username] = map[Location]bool{}
+		}
+		movedInto[p.Username][t.order.ToLocation] = true
+	}
+	w.transits = travelling
+	if len(movedInto) > 0 {
+		w.fightOverlaps(movedInto, res)
+	}
+}
+
+// land has every unit still on its way arrive, when a turn ends.
+func (w *World) land(res *TurnResolution) {
+	w.advance(math.MaxInt64, res)
+}
+
+func (w *World) checkNotTravelling(order MoveOrder) error {
+	for _, t := range w.transits {
+		if t.order.Username != order.Username {
+			continue
+		}
+		for _, id := range t.order.UnitIDs {
+			for _, moving := range order.UnitIDs {
+				if id == moving {
+					return fmt.Errorf("error: unit %v is already on its way to %s", id, t.order.ToLocation)
+				}
+			}
+		}
+	}
+	return nil
+}
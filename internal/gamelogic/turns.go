@@ -0,0 +1,145 @@
+package gamelogic
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// TurnResolution is everything that happened when the server resolved the
+// orders of a turn, in the order it should be published.
+type TurnResolution struct {
+	Moves  []ArmyMove
+	Deltas []StateDelta
+	Wars   []WarResult
+}
+
+func (w *World) QueueSpawn(order SpawnOrder) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pendingSpawns = append(w.pendingSpawns, order)
+}
+
+func (w *World) QueueMove(order MoveOrder) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pendingMoves = append(w.pendingMoves, order)
+}
+
+// ResolveTurn lands the units still on their way from real-time mode, applies
+// every queued spawn, then every queued move, and finally fights every war
+// between players that ended up sharing a location.
+func (w *World) ResolveTurn() TurnResolution {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	res := TurnResolution{}
+	reject := func(username string, err error) {
+		delta := w.resync(username)
+		delta.Rejected = err.Error()
+		res.Deltas = append(res.Deltas, delta)
+	}
+
+	w.land(&res)
+	for _, order := range w.pendingSpawns {
+		delta, err := w.spawn(order)
+		if err != nil {
+			reject(order.Username, err)
+			continue
+		}
+		res.Deltas = append(res.Deltas, delta)
+	}
+
+	movedInto := map[string]map[Location]bool{}
+	for _, order := range w.pendingMoves {
+		mv, delta, err := w.move(order)
+		if err != nil {
+			reject(order.Username, err)
+			continue
+		}
+		if movedInto[order.Username] == nil {
+			movedInto[order.Username] = map[Location]bool{}
+		}
+		movedInto[order.Username][order.ToLocation] = true
+		res.Moves = append(res.Moves, mv)
+		res.Deltas = append(res.Deltas, delta)
+	}
+	w.pendingSpawns = nil
+	w.pendingMoves = nil
+	w.fightOverlaps(movedInto, &res)
+	return res
+}
+
+// fightOverlaps fights every war between players sharing a location. Players
+// who just moved into it attack the ones who were already there.
+func (w *World) fightOverlaps(movedInto map[string]map[Location]bool, res *TurnResolution) {
+	usernames := []string{}
+	for username := range w.players {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+	for i, a := range usernames {
+		for _, b := range usernames[i+1:] {
+			for {
+				loc := getOverlappingLocation(*w.players[a], *w.players[b], w.alliances)
+				if loc == "" {
+					break
+				}
+				attacker, defender := a, b
+				if movedInto[b][loc] && !movedInto[a][loc] {
+					attacker, defender = b, a
+				}
+				wr, err := w.fight(attacker, defender)
+				if err != nil {
+					break
+				}
+				res.Wars = append(res.Wars, wr)
+				if len(wr.Casualties[a]) == 0 && len(wr.Casualties[b]) == 0 && len(wr.Wounded[a]) == 0 && len(wr.Wounded[b]) == 0 {
+					break
+				}
+			}
+		}
+	}
+}
+
+func (gs *GameState) isTurnBased() bool {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.TurnBased
+}
+
+func (gs *GameState) currentTurn() int {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.Turn
+}
+
+func (gs *GameState) queueOrder(description string) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.pendingOrders = append(gs.pendingOrders, description)
+}
+
+func (gs *GameState) getPendingOrders() []string {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return append([]string{}, gs.pendingOrders...)
+}
+
+func (gs *GameState) HandleTurnStarted(ts routing.TurnStarted) {
+	defer fmt.Println("------------------------")
+	fmt.Println()
+	fmt.Printf("==== Turn %d Started ====\n", ts.Turn)
+	fmt.Printf("You have %v to issue your orders.\n", ts.Duration)
+	gs.record(Event{Kind: EventTurn, Turn: ts.Turn})
+}
+
+func (gs *GameState) HandleTurnEnded(te routing.TurnEnded) {
+	defer fmt.Println("------------------------")
+	fmt.Println()
+	fmt.Printf("==== Turn %d Ended ====\n", te.Turn)
+	if pending := gs.getPendingOrders(); len(pending) > 0 {
+		fmt.Printf("Resolving your %d order(s)...\n", len(pending))
+	}
+}
@@ -14,92 +14,146 @@ const (
 	WarOutcomeDraw
 )
 
-func (gs *GameState) HandleWar(rw RecognitionOfWar) (outcome WarOutcome, winner string, loser string) {
-	defer fmt.Println("------------------------")
-	fmt.Println()
-	fmt.Println("==== War Declared ====")
-	fmt.Printf("%s has declared war on %s!\n", rw.Attacker.Username, rw.Defender.Username)
-
-	player := gs.GetPlayerSnap()
-
-	if player.Username == rw.Defender.Username {
-		fmt.Printf("%s, you published the war.\n", player.Username)
-		return WarOutcomeNotInvolved, "", ""
+// ResolveWar fights the war between attacker and defender at the first
+// location they share. It is pure: the returned result lists the casualties
+// and wounded units, and every participant applies them to its own state.
+func (r *Ruleset) ResolveWar(attacker, defender Player, seed int64) (WarResult, error) {
+	overlappingLocation := getOverlappingLocation(attacker, defender, nil)
+	if overlappingLocation == "" {
+		return WarResult{}, fmt.Errorf("%s and %s have no units in the same location", attacker.Username, defender.Username)
 	}
 
-	if player.Username != rw.Attacker.Username {
-		fmt.Printf("%s, you are not involved in this war.\n", player.Username)
-		return WarOutcomeNotInvolved, "", ""
+	attackerUnits := unitsInLocation(attacker, overlappingLocation)
+	defenderUnits := unitsInLocation(defender, overlappingLocation)
+	result := WarResult{
+		Attacker:      attacker.Username,
+		Defender:      defender.Username,
+		Location:      overlappingLocation,
+		Seed:          seed,
+		AttackerUnits: attackerUnits,
+		DefenderUnits: defenderUnits,
+		Casualties:    map[string][]int{},
+		Wounded:       map[string][]Unit{},
 	}
 
-	overlappingLocation := getOverlappingLocation(rw.Attacker, rw.Defender)
-	if overlappingLocation == "" {
-		fmt.Printf("Error! No units are in the same location. No war will be fought.\n")
-		return WarOutcomeNoUnits, "", ""
+	attackerSurvivors, defenderSurvivors := r.Battle(attackerUnits, defenderUnits, overlappingLocation, seed)
+	result.Casualties[attacker.Username], result.Wounded[attacker.Username] = losses(attackerUnits, attackerSurvivors)
+	result.Casualties[defender.Username], result.Wounded[defender.Username] = losses(defenderUnits, defenderSurvivors)
+
+	attackerPower := r.Power(attackerSurvivors)
+	defenderPower := r.Power(defenderSurvivors)
+	switch {
+	case len(defenderSurvivors) == 0 && len(attackerSurvivors) > 0, attackerPower > defenderPower:
+		result.Winner, result.Loser = attacker.Username, defender.Username
+	case len(attackerSurvivors) == 0 && len(defenderSurvivors) > 0, defenderPower > attackerPower:
+		result.Winner, result.Loser = defender.Username, attacker.Username
 	}
+	return result, nil
+}
 
-	attackerUnits := []Unit{}
-	defenderUnits := []Unit{}
-	for _, unit := range rw.Attacker.Units {
-		if unit.Location == overlappingLocation {
-			attackerUnits = append(attackerUnits, unit)
-		}
+// losses compares the units that went into battle with those that came out and
+// returns the IDs of the dead and the survivors that lost hit points.
+func losses(before, after []Unit) ([]int, []Unit) {
+	survived := map[int]Unit{}
+	for _, unit := range after {
+		survived[unit.ID] = unit
 	}
-	for _, unit := range rw.Defender.Units {
-		if unit.Location == overlappingLocation {
-			defenderUnits = append(defenderUnits, unit)
+	dead := []int{}
+	wounded := []Unit{}
+	for _, unit := range before {
+		s, ok := survived[unit.ID]
+		if !ok {
+			dead = append(dead, unit.ID)
+			continue
+		}
+		if s.HP != unit.HP {
+			wounded = append(wounded, s)
 		}
 	}
+	return dead, wounded
+}
+
+func (wr WarResult) IsDraw() bool {
+	return wr.Winner == ""
+}
 
-	fmt.Printf("%s's units:\n", rw.Attacker.Username)
-	for _, unit := range attackerUnits {
+func (wr WarResult) OutcomeFor(username string) WarOutcome {
+	switch {
+	case username != wr.Attacker && username != wr.Defender:
+		return WarOutcomeNotInvolved
+	case wr.IsDraw():
+		return WarOutcomeDraw
+	case username == wr.Winner:
+		return WarOutcomeYouWon
+	default:
+		return WarOutcomeOpponentWon
+	}
+}
+
+func (gs *GameState) HandleWarResult(wr WarResult) WarOutcome {
+	defer fmt.Println("------------------------")
+	fmt.Println()
+	fmt.Println("==== War Declared ====")
+	fmt.Printf("%s has declared war on %s in %s!\n", wr.Attacker, wr.Defender, wr.Location)
+
+	outcome := wr.OutcomeFor(gs.GetUsername())
+	if outcome == WarOutcomeNotInvolved {
+		fmt.Printf("%s, you are not involved in this war.\n", gs.GetUsername())
+		return outcome
+	}
+
+	fmt.Printf("%s's units:\n", wr.Attacker)
+	for _, unit := range wr.AttackerUnits {
 		fmt.Printf("  * %v\n", unit.Rank)
 	}
-	fmt.Printf("%s's units:\n", rw.Defender.Username)
-	for _, unit := range defenderUnits {
+	fmt.Printf("%s's units:\n", wr.Defender)
+	for _, unit := range wr.DefenderUnits {
 		fmt.Printf("  * %v\n", unit.Rank)
 	}
-	attackerPower := unitsToPowerLevel(attackerUnits)
-	defenderPower := unitsToPowerLevel(defenderUnits)
-	fmt.Printf("Attacker has a power level of %v\n", attackerPower)
-	fmt.Printf("Defender has a power level of %v\n", defenderPower)
-	if attackerPower > defenderPower {
-		fmt.Printf("%s has won the war!\n", rw.Attacker.Username)
-		if player.Username == rw.Defender.Username {
-			fmt.Println("You have lost the war!")
-			gs.removeUnitsInLocation(overlappingLocation)
-			fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-			return WarOutcomeOpponentWon, rw.Attacker.Username, rw.Defender.Username
-		}
-		return WarOutcomeYouWon, rw.Attacker.Username, rw.Defender.Username
-	} else if defenderPower > attackerPower {
-		fmt.Printf("%s has won the war!\n", rw.Defender.Username)
-		if player.Username == rw.Attacker.Username {
-			fmt.Println("You have lost the war!")
-			gs.removeUnitsInLocation(overlappingLocation)
-			fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-			return WarOutcomeOpponentWon, rw.Defender.Username, rw.Attacker.Username
+	fmt.Printf("Attacker has a power level of %v\n", gs.rules.Power(wr.AttackerUnits))
+	fmt.Printf("Defender has a power level of %v\n", gs.rules.Power(wr.DefenderUnits))
+	if replayed, err := gs.rules.ResolveWar(
+		Player{Username: wr.Attacker, Units: unitMap(wr.AttackerUnits)},
+		Player{Username: wr.Defender, Units: unitMap(wr.DefenderUnits)},
+		wr.Seed,
+	); err != nil || replayed.Winner != wr.Winner || len(replayed.Casualties[gs.GetUsername()]) != len(wr.Casualties[gs.GetUsername()]) {
+		fmt.Println("Warning: replaying this war locally gives a different outcome than the server's.")
+	}
+
+	switch outcome {
+	case WarOutcomeYouWon:
+		fmt.Printf("%s has won the war!\n", wr.Winner)
+	case WarOutcomeOpponentWon:
+		fmt.Printf("%s has won the war!\n", wr.Winner)
+		fmt.Println("You have lost the war!")
+	case WarOutcomeDraw:
+		fmt.Println("The war ended in a draw!")
+	}
+
+	gs.record(Event{Kind: EventWar, War: &wr})
+	if casualties := wr.Casualties[gs.GetUsername()]; len(casualties) > 0 {
+		fmt.Printf("%d of your units in %s have been killed.\n", len(casualties), wr.Location)
+	}
+	for _, unit := range wr.Wounded[gs.GetUsername()] {
+		fmt.Printf("Your %s %v is wounded and has %d hit points left.\n", unit.Rank, unit.ID, unit.HP)
+	}
+	return outcome
+}
+
+func unitsInLocation(p Player, loc Location) []Unit {
+	units := []Unit{}
+	for _, unit := range p.Units {
+		if unit.Location == loc {
+			units = append(units, unit)
 		}
-		return WarOutcomeYouWon, rw.Defender.Username, rw.Attacker.Username
 	}
-	fmt.Println("The war ended in a draw!")
-	fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
-	gs.removeUnitsInLocation(overlappingLocation)
-	return WarOutcomeDraw, rw.Attacker.Username, rw.Defender.Username
+	return units
 }
 
-func unitsToPowerLevel(units []Unit) int {
-	power := 0
+func unitMap(units []Unit) map[int]Unit {
+	m := map[int]Unit{}
 	for _, unit := range units {
-		if unit.Rank == RankArtillery {
-			power += 10
-		}
-		if unit.Rank == RankCavalry {
-			power += 5
-		}
-		if unit.Rank == RankInfantry {
-			power += 1
-		}
+		m[unit.ID] = unit
 	}
-	return power
+	return m
 }
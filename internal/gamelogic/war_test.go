@@ -0,0 +1,244 @@
+package gamelogic
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// warRules fights without dice, so every battle below can be worked out by
+// hand: each shooter hits the first enemy still standing for its power.
+const warRules = `{
+  "name": "war-test",
+  "income_interval_seconds": 10,
+  "ranks": [
+    {"name": "infantry", "power": 1, "hp": 3, "cost": 1, "range": 1},
+    {"name": "cavalry", "power": 5, "hp": 8, "cost": 4, "range": 1},
+    {"name": "artillery", "power": 10, "hp": 12, "cost": 8, "range": 1}
+  ],
+  "locations": [
+    {"name": "plains", "income": 1},
+    {"name": "fort", "income": 1, "defense_bonus": 1},
+    {"name": "hills", "income": 1}
+  ],
+  "combat": {"rounds": 3, "dice": false}
+}`
+
+func parseWarRules(t *testing.T) *Ruleset {
+	t.Helper()
+	rules, err := ParseRuleset([]byte(warRules))
+	if err != nil {
+		t.Fatalf("ParseRuleset() error = %v", err)
+	}
+	return rules
+}
+
+func army(owner string, loc Location, ranks ...UnitRank) map[int]Unit {
+	units := map[int]Unit{}
+	for i, rank := range ranks {
+		units[i+1] = Unit{ID: i + 1, Owner: owner, Rank: rank, Location: loc}
+	}
+	return units
+}
+
+// hitPoints maps the IDs of the units a player kept to their hit points, 0
+// standing for untouched units.
+func hitPoints(p Player) map[int]int {
+	hp := map[int]int{}
+	for id, unit := range p.Units {
+		hp[id] = unit.HP
+	}
+	return hp
+}
+
+func TestResolveWar(t *testing.T) {
+	rules := parseWarRules(t)
+	tests := []struct {
+		name            string
+		location        Location
+		attacker        []UnitRank
+		defender        []UnitRank
+		winner          string
+		attackerOutcome WarOutcome
+		defenderOutcome WarOutcome
+		attackerKeeps   map[int]int
+		defenderKeeps   map[int]int
+	}{
+		{
+			name:            "attacker wins",
+			location:        "plains",
+			attacker:        []UnitRank{RankArtillery},
+			defender:        []UnitRank{RankInfantry},
+			winner:          "alice",
+			attackerOutcome: WarOutcomeYouWon,
+			defenderOutcome: WarOutcomeOpponentWon,
+			attackerKeeps:   map[int]int{1: 11, 9: 0},
+			defenderKeeps:   map[int]int{},
+		},
+		{
+			name:            "defender wins",
+			location:        "plains",
+			attacker:        []UnitRank{RankInfantry},
+			defender:        []UnitRank{RankArtillery},
+			winner:          "bob",
+			attackerOutcome: WarOutcomeOpponentWon,
+			defenderOutcome: WarOutcomeYouWon,
+			attackerKeeps:   map[int]int{9: 0},
+			defenderKeeps:   map[int]int{1: 11},
+		},
+		{
+			name:            "defender wins behind walls",
+			location:        "fort",
+			attacker:        []UnitRank{RankCavalry},
+			defender:        []UnitRank{RankCavalry},
+			winner:          "bob",
+			attackerOutcome: WarOutcomeOpponentWon,
+			defenderOutcome: WarOutcomeYouWon,
+			attackerKeeps:   map[int]int{9: 0},
+			defenderKeeps:   map[int]int{1: 5},
+		},
+		{
+			name:            "draw with survivors",
+			location:        "plains",
+			attacker:        []UnitRank{RankInfantry, RankInfantry},
+			defender:        []UnitRank{RankInfantry, RankInfantry},
+			attackerOutcome: WarOutcomeDraw,
+			defenderOutcome: WarOutcomeDraw,
+			attackerKeeps:   map[int]int{2: 2, 9: 0},
+			defenderKeeps:   map[int]int{2: 2},
+		},
+		{
+			name:            "draw without survivors",
+			location:        "plains",
+			attacker:        []UnitRank{RankCavalry},
+			defender:        []UnitRank{RankCavalry},
+			attackerOutcome: WarOutcomeDraw,
+			defenderOutcome: WarOutcomeDraw,
+			attackerKeeps:   map[int]int{9: 0},
+			defenderKeeps:   map[int]int{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			alice := NewGameState("alice", rules)
+			alice.Player.Units = army("alice", tc.location, tc.attacker...)
+			// A unit away from the battle is never touched by it.
+			alice.Player.Units[9] = Unit{ID: 9, Owner: "alice", Rank: RankInfantry, Location: "hills"}
+			bob := NewGameState("bob", rules)
+			bob.Player.Units = army("bob", tc.location, tc.defender...)
+
+			wr, err := rules.ResolveWar(alice.GetPlayerSnap(), bob.GetPlayerSnap(), 42)
+			if err != nil {
+				t.Fatalf("ResolveWar() error = %v", err)
+			}
+			if wr.Location != tc.location {
+				t.Errorf("Location = %s, want %s", wr.Location, tc.location)
+			}
+			if wr.Winner != tc.winner {
+				t.Errorf("Winner = %q, want %q", wr.Winner, tc.winner)
+			}
+			if got := wr.OutcomeFor("carol"); got != WarOutcomeNotInvolved {
+				t.Errorf("OutcomeFor(carol) = %v, want %v", got, WarOutcomeNotInvolved)
+			}
+
+			sides := []struct {
+				gs      *GameState
+				outcome WarOutcome
+				keeps   map[int]int
+			}{
+				{alice, tc.attackerOutcome, tc.attackerKeeps},
+				{bob, tc.defenderOutcome, tc.defenderKeeps},
+			}
+			for _, side := range sides {
+				username := side.gs.GetUsername()
+				if got := wr.OutcomeFor(username); got != side.outcome {
+					t.Errorf("OutcomeFor(%s) = %v, want %v", username, got, side.outcome)
+				}
+				if got := side.gs.HandleWarResult(wr); got != side.outcome {
+					t.Errorf("HandleWarResult() for %s = %v, want %v", username, got, side.outcome)
+				}
+				if got := hitPoints(side.gs.GetPlayerSnap()); !reflect.DeepEqual(got, side.keeps) {
+					t.Errorf("%s keeps %v, want %v", username, got, side.keeps)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveWarIsSeeded(t *testing.T) {
+	rules := DefaultRuleset()
+	attacker := Player{Username: "alice", Units: army("alice", "europe", RankInfantry, RankCavalry, RankArtillery)}
+	defender := Player{Username: "bob", Units: army("bob", "europe", RankCavalry, RankCavalry, RankInfantry)}
+	for _, seed := range []int64{1, 7, 42, 1000} {
+		first, err := rules.ResolveWar(attacker, defender, seed)
+		if err != nil {
+			t.Fatalf("ResolveWar() error = %v", err)
+		}
+		again, err := rules.ResolveWar(attacker, defender, seed)
+		if err != nil {
+			t.Fatalf("ResolveWar() error = %v", err)
+		}
+		if !reflect.DeepEqual(sortedWar(first), sortedWar(again)) {
+			t.Errorf("seed %d: ResolveWar() = %+v, then %+v", seed, first, again)
+		}
+	}
+}
+
+// sortedWar orders the units and casualties of wr by ID, since ResolveWar
+// lists them in map order.
+func sortedWar(wr WarResult) WarResult {
+	byID := func(a, b Unit) int { return a.ID - b.ID }
+	slices.SortFunc(wr.AttackerUnits, byID)
+	slices.SortFunc(wr.DefenderUnits, byID)
+	for _, ids := range wr.Casualties {
+		slices.Sort(ids)
+	}
+	for _, units := range wr.Wounded {
+		slices.SortFunc(units, byID)
+	}
+	return wr
+}
+
+func TestHandleWarResultNotInvolved(t *testing.T) {
+	rules := parseWarRules(t)
+	attacker := Player{Username: "alice", Units: army("alice", "plains", RankArtillery)}
+	defender := Player{Username: "bob", Units: army("bob", "plains", RankInfantry)}
+	wr, err := rules.ResolveWar(attacker, defender, 1)
+	if err != nil {
+		t.Fatalf("ResolveWar() error = %v", err)
+	}
+	carol := NewGameState("carol", rules)
+	carol.Player.Units = army("carol", "plains", RankInfantry)
+	if got := carol.HandleWarResult(wr); got != WarOutcomeNotInvolved {
+		t.Errorf("HandleWarResult() = %v, want %v", got, WarOutcomeNotInvolved)
+	}
+	if got := hitPoints(carol.GetPlayerSnap()); !reflect.DeepEqual(got, map[int]int{1: 0}) {
+		t.Errorf("carol keeps %v, want the unit untouched", got)
+	}
+}
+
+func TestAdvanceFightsOnArrival(t *testing.T) {
+	w := NewWorld(DefaultRuleset())
+	w.Join("alice")
+	w.Join("bob")
+	w.players["alice"].Units = army("alice", "europe", RankInfantry)
+	w.players["bob"].Units = army("bob", "africa", RankArtillery)
+	travelTime, err := w.Move(MoveOrder{Username: "bob", UnitIDs: []int{1}, ToLocation: "europe"})
+	if err != nil {
+		t.Fatalf("Move() error = %v", err)
+	}
+
+	if res := w.Advance(travelTime / 2); len(res.Moves) != 0 || len(res.Wars) != 0 {
+		t.Fatalf("Advance() halfway = %+v, want nothing to happen", res)
+	}
+	res := w.Advance(travelTime)
+	if len(res.Moves) != 1 {
+		t.Fatalf("Advance() moves = %+v, want the one of bob", res.Moves)
+	}
+	if len(res.Wars) == 0 {
+		t.Fatal("Advance() fought no war in europe")
+	}
+	if wr := res.Wars[0]; wr.Attacker != "bob" || wr.Defender != "alice" || wr.Location != "europe" {
+		t.Errorf("war = %s against %s in %s, want bob against alice in europe", wr.Attacker, wr.Defender, wr.Location)
+	}
+}
@@ -0,0 +1,214 @@
+package gamelogic
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// World is the server's authoritative model of every player and unit.
+type World struct {
+	rules         *Ruleset
+	players       map[string]*Player
+	pendingSpawns []SpawnOrder
+	pendingMoves  []MoveOrder
+	transits      []transit
+	alliances     *Alliances
+	mu            *sync.RWMutex
+}
+
+func NewWorld(rules *Ruleset) *World {
+	return &World{
+		rules:     rules,
+		players:   map[string]*Player{},
+		alliances: NewAlliances(),
+		mu:        &sync.RWMutex{},
+	}
+}
+
+func (w *World) Rules() *Ruleset {
+	return w.rules
+}
+
+// Join adds username to the world with the starting treasury, unless they
+// already joined, and returns everything the server knows about them.
+func (w *World) Join(username string) StateDelta {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.players[username]; !ok {
+		w.players[username] = &Player{Username: username, Units: map[int]Unit{}, NextUnitID: 1, Treasury: w.rules.StartingTreasury}
+	}
+	return w.resync(username)
+}
+
+func (w *World) Joined(username string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	_, ok := w.players[username]
+	return ok
+}
+
+func (w *World) player(username string) (*Player, error) {
+	p, ok := w.players[username]
+	if !ok {
+		return nil, fmt.Errorf("%s has not joined the game", username)
+	}
+	return p, nil
+}
+
+func (w *World) Spawn(order SpawnOrder) (StateDelta, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.spawn(order)
+}
+
+func (w *World) spawn(order SpawnOrder) (StateDelta, error) {
+	p, err := w.player(order.Username)
+	if err != nil {
+		return StateDelta{}, err
+	}
+	if err := w.rules.ValidateSpawn(*p, order.Unit); err != nil {
+		return StateDelta{}, err
+	}
+	p.Units[order.Unit.ID] = order.Unit
+	p.NextUnitID++
+	p.Treasury -= w.rules.ranks[order.Unit.Rank].Cost
+	return StateDelta{Username: p.Username, Upserted: []Unit{order.Unit}, NextUnitID: p.NextUnitID, Treasury: p.Treasury}, nil
+}
+
+// move moves units at once, as at the end of a turn.
+func (w *World) move(order MoveOrder) (ArmyMove, StateDelta, error) {
+	p, err := w.player(order.Username)
+	if err != nil {
+		return ArmyMove{}, StateDelta{}, err
+	}
+	if err := w.checkNotTravelling(order); err != nil {
+		return ArmyMove{}, StateDelta{}, err
+	}
+	travelTime, err := w.rules.ValidateMove(*p, order.UnitIDs, order.ToLocation)
+	if err != nil {
+		return ArmyMove{}, StateDelta{}, err
+	}
+	mv, delta := w.place(p, order.UnitIDs, order.ToLocation)
+	mv.TravelTime = travelTime
+	return mv, delta, nil
+}
+
+func (w *World) place(p *Player, unitIDs []int, to Location) (ArmyMove, StateDelta) {
+	moved := ApplyMove(*p, unitIDs, to)
+	for _, unit := range moved {
+		p.Units[unit.ID] = unit
+	}
+	mv := ArmyMove{
+		Player:     copyPlayer(*p),
+		Units:      moved,
+		ToLocation: to,
+	}
+	return mv, StateDelta{Username: p.Username, Upserted: moved, Treasury: p.Treasury}
+}
+
+func (w *World) fight(attacker, defender string) (WarResult, error) {
+	if w.alliances.Allied(attacker, defender) {
+		return WarResult{}, fmt.Errorf("%s and %s are allies", attacker, defender)
+	}
+	a, err := w.player(attacker)
+	if err != nil {
+		return WarResult{}, err
+	}
+	d, err := w.player(defender)
+	if err != nil {
+		return WarResult{}, err
+	}
+	result, err := w.rules.ResolveWar(*a, *d, rand.Int63())
+	if err != nil {
+		return WarResult{}, err
+	}
+	for username, ids := range result.Casualties {
+		p := w.players[username]
+		for _, id := range ids {
+			delete(p.Units, id)
+		}
+	}
+	for username, units := range result.Wounded {
+		p := w.players[username]
+		for _, unit := range units {
+			p.Units[unit.ID] = unit
+		}
+	}
+	return result, nil
+}
+
+// Resync returns a full delta with every unit the server knows about for
+// username, which is empty when they have not joined.
+func (w *World) Resync(username string) StateDelta {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.resync(username)
+}
+
+func (w *World) resync(username string) StateDelta {
+	p, ok := w.players[username]
+	if !ok {
+		return StateDelta{Username: username, Full: true}
+	}
+	units := []Unit{}
+	for _, u := range p.Units {
+		units = append(units, u)
+	}
+	return StateDelta{Username: username, Full: true, Upserted: units, NextUnitID: p.NextUnitID, Treasury: p.Treasury}
+}
+
+// CollectIncome credits every player with the income of the territories they
+// control and returns a delta for each player whose treasury changed.
+func (w *World) CollectIncome() []StateDelta {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	players := []Player{}
+	for _, p := range w.players {
+		players = append(players, *p)
+	}
+	territories := Territories(players)
+	deltas := []StateDelta{}
+	for _, p := range w.players {
+		income := w.rules.Income(controlledBy(territories, p.Username))
+		if income == 0 {
+			continue
+		}
+		p.Treasury += income
+		deltas = append(deltas, StateDelta{Username: p.Username, Treasury: p.Treasury})
+	}
+	return deltas
+}
+
+func (w *World) GetPlayerSnap(username string) (Player, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	p, ok := w.players[username]
+	if !ok {
+		return Player{}, false
+	}
+	return copyPlayer(*p), true
+}
+
+func (w *World) GetPlayersSnap() []Player {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	players := []Player{}
+	for _, p := range w.players {
+		players = append(players, copyPlayer(*p))
+	}
+	return players
+}
+
+func copyPlayer(p Player) Player {
+	units := map[int]Unit{}
+	for k, v := range p.Units {
+		units[k] = v
+	}
+	return Player{
+		Username:   p.Username,
+		Units:      units,
+		NextUnitID: p.NextUnitID,
+		Treasury:   p.Treasury,
+	}
+}
@@ -0,0 +1,214 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+// snapshotFile records where the events following the snapshot start in the
+// events file, so loading does not read the events it already covers.
+type snapshotFile struct {
+	Offset   int64
+	Snapshot gamelogic.Snapshot
+}
+
+// eventsFile is what Store needs of the events file.
+type eventsFile interface {
+	io.WriteCloser
+	Truncate(size int64) error
+}
+
+// Store keeps the events of one player in one game as JSON lines, along with
+// the latest snapshot of their state.
+type Store struct {
+	eventsPath   string
+	snapshotPath string
+	f            eventsFile
+	seq          int
+	// offset is where the last complete event ends. torn is set when a
+	// failed write may have left part of an event after it.
+	offset     int64
+	torn       bool
+	unsnapshot int
+	mu         *sync.Mutex
+}
+
+// Open opens the store of username's events in game under dir.
+func Open(dir, username, game string) (*Store, error) {
+	dir = filepath.Join(dir, username)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("could not create history directory: %v", err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, game+".jsonl"), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open history: %v", err)
+	}
+	return &Store{
+		eventsPath:   f.Name(),
+		snapshotPath: filepath.Join(dir, game+".snapshot.json"),
+		f:            f,
+		mu:           &sync.Mutex{},
+	}, nil
+}
+
+// Load returns the latest snapshot, nil when there is none, and the events
+// recorded after it. It must be called before Append, which numbers the new
+// events after the last one loaded.
+func (s *Store) Load() (*gamelogic.Snapshot, []gamelogic.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var snap *gamelogic.Snapshot
+	var from int64
+	data, err := os.ReadFile(s.snapshotPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("could not read snapshot: %v", err)
+	}
+	if err == nil {
+		sf := snapshotFile{}
+		if err := json.Unmarshal(data, &sf); err != nil {
+			return nil, nil, fmt.Errorf("invalid snapshot %s: %v", s.snapshotPath, err)
+		}
+		snap, from = &sf.Snapshot, sf.Offset
+		s.seq = snap.Seq
+	}
+	info, err := os.Stat(s.eventsPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read history: %v", err)
+	}
+	// The events file was cut short after the snapshot was saved, reading it
+	// from the offset would miss events and truncating to it would grow it.
+	if from > info.Size() {
+		from = 0
+	}
+
+	events, end, err := readEvents(s.eventsPath, from)
+	if err != nil {
+		return nil, nil, err
+	}
+	if snap != nil {
+		events = dropThrough(events, snap.Seq)
+	}
+	if len(events) > 0 {
+		s.seq = max(s.seq, events[len(events)-1].Seq)
+	}
+	if err := s.f.Truncate(end); err != nil {
+		return nil, nil, fmt.Errorf("could not repair history: %v", err)
+	}
+	s.offset = end
+	s.torn = false
+	s.unsnapshot = len(events)
+	return snap, events, nil
+}
+
+// Append numbers e and writes it to the events file, returning the number of
+// events recorded since the last snapshot.
+func (s *Store) Append(e gamelogic.Event) (gamelogic.Event, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.Seq = s.seq + 1
+	data, err := json.Marshal(e)
+	if err != nil {
+		return e, s.unsnapshot, fmt.Errorf("could not encode event: %v", err)
+	}
+	// Whatever a failed write left would corrupt the line written after it.
+	if s.torn {
+		if err := s.f.Truncate(s.offset); err != nil {
+			return e, s.unsnapshot, fmt.Errorf("could not repair history: %v", err)
+		}
+		s.torn = false
+	}
+	n, err := s.f.Write(append(data, '\n'))
+	if err != nil {
+		s.torn = n > 0 && s.f.Truncate(s.offset) != nil
+		return e, s.unsnapshot, fmt.Errorf("could not record event: %v", err)
+	}
+	s.seq = e.Seq
+	s.offset += int64(n)
+	s.unsnapshot++
+	return e, s.unsnapshot, nil
+}
+
+// SaveSnapshot replaces the snapshot with snap, which must cover every event
+// appended so far.
+func (s *Store) SaveSnapshot(snap gamelogic.Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, err := json.Marshal(snapshotFile{Offset: s.offset, Snapshot: snap})
+	if err != nil {
+		return fmt.Errorf("could not encode snapshot: %v", err)
+	}
+	tmp := s.snapshotPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("could not save snapshot: %v", err)
+	}
+	if err := os.Rename(tmp, s.snapshotPath); err != nil {
+		return fmt.Errorf("could not save snapshot: %v", err)
+	}
+	s.unsnapshot = 0
+	return nil
+}
+
+// Recent returns up to n of the last events, including those covered by the
+// snapshot.
+func (s *Store) Recent(n int) ([]gamelogic.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events, _, err := readEvents(s.eventsPath, 0)
+	if err != nil {
+		return nil, err
+	}
+	return events[max(0, len(events)-n):], nil
+}
+
+func (s *Store) Close() error {
+	return s.f.Close()
+}
+
+// readEvents reads the events file from offset, returning where the last
+// complete event ends. A line cut short by a crash is left out, Load then
+// truncates it.
+func readEvents(path string, offset int64) ([]gamelogic.Event, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not open history: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("could not read history: %v", err)
+	}
+
+	events := []gamelogic.Event{}
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return events, offset, nil
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not read history: %v", err)
+		}
+		var e gamelogic.Event
+		if err := json.Unmarshal(line, &e); err != nil {
+			return nil, 0, fmt.Errorf("invalid event in %s at byte %d: %v", path, offset, err)
+		}
+		events = append(events, e)
+		offset += int64(len(line))
+	}
+}
+
+func dropThrough(events []gamelogic.Event, seq int) []gamelogic.Event {
+	for i, e := range events {
+		if e.Seq > seq {
+			return events[i:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,327 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func openStore(t *testing.T, dir string) *Store {
+	t.Helper()
+	s, err := Open(dir, "alice", "g1")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func appendTurns(t *testing.T, s *Store, turns ...int) {
+	t.Helper()
+	for _, turn := range turns {
+		if _, _, err := s.Append(gamelogic.Event{Kind: gamelogic.EventTurn, Turn: turn}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+}
+
+func seqs(events []gamelogic.Event) []int {
+	seqs := []int{}
+	for _, e := range events {
+		seqs = append(seqs, e.Seq)
+	}
+	return seqs
+}
+
+func TestReadEvents(t *testing.T) {
+	line1 := `{"Seq":1,"Kind":"turn","Turn":1}` + "\n"
+	line2 := `{"Seq":2,"Kind":"turn","Turn":2}` + "\n"
+	tests := []struct {
+		name    string
+		content string
+		offset  int64
+		want    []int
+		wantEnd int64
+		wantErr bool
+	}{
+		{"empty", "", 0, []int{}, 0, false},
+		{"complete", line1 + line2, 0, []int{1, 2}, int64(len(line1 + line2)), false},
+		{"from an offset", line1 + line2, int64(len(line1)), []int{2}, int64(len(line1 + line2)), false},
+		{"crash mid-line", line1 + line2 + `{"Seq":3,"Ki`, 0, []int{1, 2}, int64(len(line1 + line2)), false},
+		{"line without its newline", line1 + strings.TrimSuffix(line2, "\n"), 0, []int{1}, int64(len(line1)), false},
+		{"corrupt line", line1 + "garbage\n" + line2, 0, nil, 0, true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "g1.jsonl")
+			if err := os.WriteFile(path, []byte(tc.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			events, end, err := readEvents(path, tc.offset)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("readEvents() error = %v, want error %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if got := seqs(events); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("readEvents() seqs = %v, want %v", got, tc.want)
+			}
+			if end != tc.wantEnd {
+				t.Errorf("readEvents() end = %d, want %d", end, tc.wantEnd)
+			}
+		})
+	}
+}
+
+func TestLoadRepairsTruncatedLine(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir)
+	if _, _, err := s.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	appendTurns(t, s, 1, 2, 3)
+	s.Close()
+	f, err := os.OpenFile(s.eventsPath, os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"Seq":4,"Kind":"tu`)
+	f.Close()
+
+	s = openStore(t, dir)
+	_, events, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := seqs(events); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Fatalf("Load() seqs = %v, want [1 2 3]", got)
+	}
+	appendTurns(t, s, 4)
+	s.Close()
+
+	s = openStore(t, dir)
+	_, events, err = s.Load()
+	if err != nil {
+		t.Fatalf("Load() after the repair error = %v", err)
+	}
+	if got := seqs(events); !reflect.DeepEqual(got, []int{1, 2, 3, 4}) {
+		t.Errorf("Load() after the repair seqs = %v, want [1 2 3 4]", got)
+	}
+}
+
+func TestLoadStartsAtSnapshotOffset(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir)
+	if _, _, err := s.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	appendTurns(t, s, 1, 2, 3)
+	if err := s.SaveSnapshot(gamelogic.Snapshot{Seq: 3, Turn: 3}); err != nil {
+		t.Fatalf("SaveSnapshot() error = %v", err)
+	}
+	appendTurns(t, s, 4, 5)
+	s.Close()
+
+	// Load must not read what the snapshot covers, so garbling it is harmless.
+	data, err := os.ReadFile(s.eventsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := strings.Index(string(data), "\n")
+	garbled := strings.Repeat("x", first) + string(data[first:])
+	if err := os.WriteFile(s.eventsPath, []byte(garbled), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openStore(t, dir)
+	snap, events, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if snap == nil || snap.Seq != 3 || snap.Turn != 3 {
+		t.Fatalf("Load() snapshot = %+v, want the one of event 3", snap)
+	}
+	if got := seqs(events); !reflect.DeepEqual(got, []int{4, 5}) {
+		t.Errorf("Load() seqs = %v, want [4 5]", got)
+	}
+	e, pending, err := s.Append(gamelogic.Event{Kind: gamelogic.EventTurn, Turn: 6})
+	if err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+	if e.Seq != 6 || pending != 3 {
+		t.Errorf("Append() = seq %d with %d pending, want seq 6 with 3 pending", e.Seq, pending)
+	}
+}
+
+func TestLoadIgnoresOffsetPastTheEnd(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir)
+	if _, _, err := s.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	appendTurns(t, s, 1, 2)
+	short, err := os.ReadFile(s.eventsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendTurns(t, s, 3)
+	if err := s.SaveSnapshot(gamelogic.Snapshot{Seq: 3, Turn: 3}); err != nil {
+		t.Fatalf("SaveSnapshot() error = %v", err)
+	}
+	s.Close()
+	// The events file lost its last event, the snapshot still points past it.
+	if err := os.WriteFile(s.eventsPath, short, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s = openStore(t, dir)
+	_, events, err := s.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(events) != 0 {
+		t.Errorf("Load() seqs = %v, want none after the snapshot", seqs(events))
+	}
+	info, err := os.Stat(s.eventsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(len(short)) {
+		t.Errorf("events file is %d bytes after Load(), want %d", info.Size(), len(short))
+	}
+	appendTurns(t, s, 4)
+	if got, err := readSeqs(s.eventsPath); err != nil || !reflect.DeepEqual(got, []int{1, 2, 4}) {
+		t.Errorf("events file seqs = %v (error %v), want [1 2 4]", got, err)
+	}
+}
+
+// tearingFile cuts the next writes short and fails the next truncations.
+type tearingFile struct {
+	*os.File
+	tornWrites     int
+	failedTruncate int
+}
+
+var errDiskFull = errors.New("disk full")
+
+func (f *tearingFile) Write(b []byte) (int, error) {
+	if f.tornWrites > 0 {
+		f.tornWrites--
+		n, _ := f.File.Write(b[:len(b)/2])
+		return n, errDiskFull
+	}
+	return f.File.Write(b)
+}
+
+func (f *tearingFile) Truncate(size int64) error {
+	if f.failedTruncate > 0 {
+		f.failedTruncate--
+		return errDiskFull
+	}
+	return f.File.Truncate(size)
+}
+
+func TestAppendRepairsTornWrites(t *testing.T) {
+	for _, failedTruncate := range []int{0, 1} {
+		t.Run(fmt.Sprintf("%d failed truncations", failedTruncate), func(t *testing.T) {
+			dir := t.TempDir()
+			s := openStore(t, dir)
+			if _, _, err := s.Load(); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			appendTurns(t, s, 1)
+			s.f = &tearingFile{File: s.f.(*os.File), tornWrites: 1, failedTruncate: failedTruncate}
+			if _, _, err := s.Append(gamelogic.Event{Kind: gamelogic.EventTurn, Turn: 2}); err == nil {
+				t.Fatal("Append() with a torn write succeeded")
+			}
+			appendTurns(t, s, 2, 3)
+			s.Close()
+
+			s = openStore(t, dir)
+			_, events, err := s.Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got := seqs(events); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+				t.Errorf("Load() seqs = %v, want [1 2 3]", got)
+			}
+		})
+	}
+}
+
+func readSeqs(path string) ([]int, error) {
+	events, _, err := readEvents(path, 0)
+	return seqs(events), err
+}
+
+// play drives gs through a short game, every change being recorded.
+func play(t *testing.T, gs *gamelogic.GameState) {
+	t.Helper()
+	gs.HandlePause(routing.PlayingState{})
+	for _, loc := range []string{"europe", "europe", "asia"} {
+		if _, err := gs.CommandSpawn([]string{"spawn", loc, gamelogic.RankInfantry}); err != nil {
+			t.Fatalf("CommandSpawn() error = %v", err)
+		}
+	}
+	wounded := gamelogic.Unit{ID: 3, Owner: "alice", Rank: gamelogic.RankInfantry, Location: "asia", HP: 1}
+	gs.HandleStateDelta(gamelogic.StateDelta{Username: "alice", Upserted: []gamelogic.Unit{wounded}, NextUnitID: 4, Treasury: 12})
+	gs.HandleWarResult(gamelogic.WarResult{
+		Attacker:   "bob",
+		Defender:   "alice",
+		Location:   "europe",
+		Winner:     "bob",
+		Loser:      "alice",
+		Casualties: map[string][]int{"alice": {1}},
+		Wounded:    map[string][]gamelogic.Unit{},
+	})
+	gs.HandlePause(routing.PlayingState{IsPaused: true, TurnBased: true})
+	gs.HandleTurnStarted(routing.TurnStarted{Turn: 2})
+}
+
+func TestRebuildGameStateMatchesLive(t *testing.T) {
+	rules := gamelogic.DefaultRuleset()
+	for _, snapshotAt := range []int{0, 1, 4, 8} {
+		t.Run(fmt.Sprintf("snapshot at %d", snapshotAt), func(t *testing.T) {
+			dir := t.TempDir()
+			s := openStore(t, dir)
+			if _, _, err := s.Load(); err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			live := gamelogic.NewGameState("alice", rules)
+			live.SetRecorder(func(e gamelogic.Event) {
+				e, _, err := s.Append(e)
+				if err != nil {
+					t.Errorf("Append() error = %v", err)
+				}
+				if e.Seq == snapshotAt {
+					if err := s.SaveSnapshot(live.Snapshot(e.Seq)); err != nil {
+						t.Errorf("SaveSnapshot() error = %v", err)
+					}
+				}
+			})
+			play(t, live)
+			s.Close()
+
+			s = openStore(t, dir)
+			snap, events, err := s.Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if (snap != nil) != (snapshotAt > 0) {
+				t.Fatalf("Load() snapshot = %+v, want one at %d", snap, snapshotAt)
+			}
+			rebuilt := gamelogic.RebuildGameState("alice", rules, snap, events)
+			if got, want := rebuilt.Snapshot(0), live.Snapshot(0); !reflect.DeepEqual(got, want) {
+				t.Errorf("rebuilt state = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
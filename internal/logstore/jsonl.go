@@ -0,0 +1,152 @@
+package logstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// JSONLSink appends the game logs to a file as JSON lines, rotating it as
+// configured.
+type JSONLSink struct {
+	path string
+	rot  Rotation
+	f    *os.File
+	size int64
+	day  string
+	mu   *sync.Mutex
+}
+
+func OpenJSONL(path string, rot Rotation) (*JSONLSink, error) {
+	s := &JSONLSink{path: path, rot: rot, mu: &sync.Mutex{}}
+	if err := s.open(time.Now()); err != nil {
+		return nil, err
+	}
+	if err := prune(path, rot, time.Now()); err != nil {
+		s.f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *JSONLSink) open(now time.Time) error {
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open logs file: %v", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("could not open logs file: %v", err)
+	}
+	s.f, s.size, s.day = f, info.Size(), day(now)
+	if s.size > 0 {
+		s.day = day(info.ModTime())
+	}
+	return nil
+}
+
+// Write appends the logs and syncs the file, rotating it first when they
+// would not fit or a new day started.
+func (s *JSONLSink) Write(logs ...routing.GameLog) error {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	for _, gl := range logs {
+		if err := enc.Encode(gl); err != nil {
+			return fmt.Errorf("could not encode game log: %v", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.size > 0 && ((s.rot.MaxSize > 0 && s.size+int64(buf.Len()) > s.rot.MaxSize) || (s.rot.Daily && s.day != day(now))) {
+		if err := s.rotate(now); err != nil {
+			return err
+		}
+	}
+	n, err := s.f.Write(buf.Bytes())
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("could not write to logs file: %v", err)
+	}
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("could not sync logs file: %v", err)
+	}
+	return nil
+}
+
+func (s *JSONLSink) rotate(now time.Time) error {
+	if err := s.f.Close(); err != nil {
+		return fmt.Errorf("could not close logs file: %v", err)
+	}
+	if err := os.Rename(s.path, rotatedName(s.path, now)); err != nil {
+		return fmt.Errorf("could not rotate logs file: %v", err)
+	}
+	if err := s.open(now); err != nil {
+		return err
+	}
+	return prune(s.path, s.rot, now)
+}
+
+// Query scans the rotated files and then the current one, skipping lines that
+// are not game logs.
+func (s *JSONLSink) Query(filter Filter) ([]routing.GameLog, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	paths, err := rotatedFiles(s.path)
+	if err != nil {
+		return nil, err
+	}
+	logs := []routing.GameLog{}
+	for _, path := range append(paths, s.path) {
+		if logs, err = scanFile(path, filter, logs); err != nil {
+			return nil, err
+		}
+	}
+	return logs, nil
+}
+
+func scanFile(path string, filter Filter, logs []routing.GameLog) ([]routing.GameLog, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return logs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open logs file: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var gl routing.GameLog
+		if err := json.Unmarshal(scanner.Bytes(), &gl); err != nil {
+			continue
+		}
+		if !filter.Match(gl) {
+			continue
+		}
+		logs = append(logs, gl)
+		if filter.Limit > 0 && len(logs) > filter.Limit {
+			logs = logs[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read logs file %s: %v", path, err)
+	}
+	return logs, nil
+}
+
+func (s *JSONLSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
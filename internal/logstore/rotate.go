@@ -0,0 +1,67 @@
+package logstore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const rotatedTimeFormat = "20060102T150405.000"
+
+// Rotation limits the log files. The current file is rotated once it would
+// grow past MaxSize bytes, or on the first write of a new day when Daily is
+// set. Only the latest Keep rotated files no older than MaxAge are kept. Zero
+// values disable a limit.
+type Rotation struct {
+	MaxSize int64
+	Daily   bool
+	Keep    int
+	MaxAge  time.Duration
+}
+
+// rotatedName inserts the rotation time before the extension of path, so
+// rotated files sort by age.
+func rotatedName(path string, now time.Time) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(path, ext), now.Format(rotatedTimeFormat), ext)
+}
+
+// rotatedFiles returns the rotated files of path, oldest first.
+func rotatedFiles(path string) ([]string, error) {
+	ext := filepath.Ext(path)
+	paths, err := filepath.Glob(strings.TrimSuffix(path, ext) + "-*" + ext)
+	if err != nil {
+		return nil, fmt.Errorf("could not list rotated logs: %v", err)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// prune deletes the rotated files of path that are past the retention limits.
+func prune(path string, rot Rotation, now time.Time) error {
+	paths, err := rotatedFiles(path)
+	if err != nil {
+		return err
+	}
+	for i, p := range paths {
+		expired := rot.Keep > 0 && i < len(paths)-rot.Keep
+		if !expired && rot.MaxAge > 0 {
+			info, err := os.Stat(p)
+			expired = err == nil && now.Sub(info.ModTime()) > rot.MaxAge
+		}
+		if !expired {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			return fmt.Errorf("could not delete old logs: %v", err)
+		}
+	}
+	return nil
+}
+
+func day(t time.Time) string {
+	return t.Format(time.DateOnly)
+}
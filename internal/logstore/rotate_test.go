@@ -0,0 +1,118 @@
+package logstore
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func gameLog(msg string) routing.GameLog {
+	return routing.GameLog{CurrentTime: time.Now(), Game: "g1", Username: "alice", Message: msg}
+}
+
+func TestJSONLRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.jsonl")
+	// Every log fits on its own, but no two fit in one file.
+	s, err := OpenJSONL(path, Rotation{MaxSize: 150})
+	if err != nil {
+		t.Fatalf("OpenJSONL() error = %v", err)
+	}
+	defer s.Close()
+	for _, msg := range []string{"first", "second"} {
+		if err := s.Write(gameLog(msg)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	rotated, err := rotatedFiles(path)
+	if err != nil {
+		t.Fatalf("rotatedFiles() error = %v", err)
+	}
+	if len(rotated) != 1 {
+		t.Fatalf("rotated files = %v, want one", rotated)
+	}
+	logs, err := s.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if got := messages(logs); !slices.Equal(got, []string{"first", "second"}) {
+		t.Errorf("Query() = %q, want both logs in order", got)
+	}
+}
+
+func TestJSONLRotatesDaily(t *testing.T) {
+	for _, daily := range []bool{true, false} {
+		path := filepath.Join(t.TempDir(), "logs.jsonl")
+		s, err := OpenJSONL(path, Rotation{Daily: daily})
+		if err != nil {
+			t.Fatalf("OpenJSONL() error = %v", err)
+		}
+		if err := s.Write(gameLog("yesterday")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		s.day = "2000-01-01"
+		if err := s.Write(gameLog("today")); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		s.Close()
+
+		rotated, err := rotatedFiles(path)
+		if err != nil {
+			t.Fatalf("rotatedFiles() error = %v", err)
+		}
+		if want := map[bool]int{true: 1, false: 0}[daily]; len(rotated) != want {
+			t.Errorf("daily %v: rotated files = %v, want %d", daily, rotated, want)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		rot  Rotation
+		want []int
+	}{
+		{"no limits", Rotation{}, []int{0, 1, 2, 3}},
+		{"keep", Rotation{Keep: 2}, []int{2, 3}},
+		{"max age", Rotation{MaxAge: 90 * time.Minute}, []int{2, 3}},
+		{"keep within max age", Rotation{Keep: 1, MaxAge: 90 * time.Minute}, []int{3}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "logs.jsonl")
+			// File i was rotated 3-i hours ago.
+			files := []string{}
+			for i := range 4 {
+				at := now.Add(time.Duration(i-3) * time.Hour)
+				name := rotatedName(path, at)
+				if err := os.WriteFile(name, []byte("{}\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(name, at, at); err != nil {
+					t.Fatal(err)
+				}
+				files = append(files, name)
+			}
+
+			if err := prune(path, tc.rot, now); err != nil {
+				t.Fatalf("prune() error = %v", err)
+			}
+			got, err := rotatedFiles(path)
+			if err != nil {
+				t.Fatalf("rotatedFiles() error = %v", err)
+			}
+			want := []string{}
+			for _, i := range tc.want {
+				want = append(want, files[i])
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("kept %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package logstore
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// Sink stores the game logs received by the server and finds them again. The
+// logs are durable once Write returns.
+type Sink interface {
+	Write(logs ...routing.GameLog) error
+	Query(f Filter) ([]routing.GameLog, error)
+	Close() error
+}
+
+// Filter selects game logs. Zero fields match everything, Text matches a case
+// insensitive substring of the message. Query returns the latest Limit logs,
+// oldest first.
+type Filter struct {
+	Username string
+	Game     string
+	Since    time.Time
+	Until    time.Time
+	Text     string
+	Limit    int
+}
+
+func (f Filter) Match(gl routing.GameLog) bool {
+	if f.Username != "" && gl.Username != f.Username {
+		return false
+	}
+	if f.Game != "" && gl.Game != f.Game {
+		return false
+	}
+	if !f.Since.IsZero() && gl.CurrentTime.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && gl.CurrentTime.After(f.Until) {
+		return false
+	}
+	return f.Text == "" || strings.Contains(strings.ToLower(gl.Message), strings.ToLower(f.Text))
+}
+
+const (
+	KindJSONL  = "jsonl"
+	KindSQLite = "sqlite"
+)
+
+// Open opens the sink of the given kind stored at path. Only file sinks are
+// rotated.
+func Open(kind, path string, rot Rotation) (Sink, error) {
+	switch kind {
+	case KindJSONL:
+		return OpenJSONL(path, rot)
+	case KindSQLite:
+		return OpenSQLite(path)
+	default:
+		return nil, fmt.Errorf("unknown log sink %q, use %s or %s", kind, KindJSONL, KindSQLite)
+	}
+}
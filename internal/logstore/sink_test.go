@@ -0,0 +1,96 @@
+package logstore
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+var queryEpoch = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+var queryLogs = []routing.GameLog{
+	{CurrentTime: queryEpoch, Game: "g1", Username: "alice", Message: "100% done"},
+	{CurrentTime: queryEpoch.Add(1 * time.Second), Game: "g1", Username: "bob", Message: "100 DONE"},
+	{CurrentTime: queryEpoch.Add(2 * time.Second), Game: "g2", Username: "alice", Message: "took a_b"},
+	{CurrentTime: queryEpoch.Add(3 * time.Second), Game: "g2", Username: "bob", Message: "took axb"},
+	{CurrentTime: queryEpoch.Add(4 * time.Second), Game: "g1", Username: "alice", Message: `c:\games`},
+	{CurrentTime: queryEpoch.Add(5 * time.Second), Game: "g1", Username: "bob", Message: "c:games"},
+}
+
+func messages(logs []routing.GameLog) []string {
+	msgs := []string{}
+	for _, gl := range logs {
+		msgs = append(msgs, gl.Message)
+	}
+	return msgs
+}
+
+func TestSinkQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"everything", Filter{}, messages(queryLogs)},
+		{"username", Filter{Username: "bob"}, []string{"100 DONE", "took axb", "c:games"}},
+		{"game", Filter{Game: "g2"}, []string{"took a_b", "took axb"}},
+		{"since and until", Filter{Since: queryEpoch.Add(time.Second), Until: queryEpoch.Add(2 * time.Second)}, []string{"100 DONE", "took a_b"}},
+		{"text ignores case", Filter{Text: "done"}, []string{"100% done", "100 DONE"}},
+		{"percent is literal", Filter{Text: "100%"}, []string{"100% done"}},
+		{"underscore is literal", Filter{Text: "a_b"}, []string{"took a_b"}},
+		{"backslash is literal", Filter{Text: `:\`}, []string{`c:\games`}},
+		{"latest first, returned oldest first", Filter{Username: "alice", Limit: 2}, []string{"took a_b", `c:\games`}},
+		{"nothing", Filter{Text: "surrender"}, []string{}},
+	}
+	for _, kind := range []string{KindJSONL, KindSQLite} {
+		t.Run(kind, func(t *testing.T) {
+			sink, err := Open(kind, filepath.Join(t.TempDir(), "logs"), Rotation{})
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer sink.Close()
+			if err := sink.Write(queryLogs...); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			for _, tc := range tests {
+				t.Run(tc.name, func(t *testing.T) {
+					logs, err := sink.Query(tc.filter)
+					if err != nil {
+						t.Fatalf("Query() error = %v", err)
+					}
+					if got := messages(logs); !slices.Equal(got, tc.want) {
+						t.Errorf("Query() = %q, want %q", got, tc.want)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestSQLiteKeepsTimes(t *testing.T) {
+	sink, err := OpenSQLite(filepath.Join(t.TempDir(), "logs.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite() error = %v", err)
+	}
+	defer sink.Close()
+	at := queryEpoch.Add(123456789 * time.Nanosecond)
+	if err := sink.Write(routing.GameLog{CurrentTime: at, Game: "g1", Username: "alice", Message: "hi"}); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	logs, err := sink.Query(Filter{})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if len(logs) != 1 || !logs[0].CurrentTime.Equal(at) {
+		t.Fatalf("Query() = %+v, want one log sent at %v", logs, at)
+	}
+}
+
+func TestOpenUnknownSink(t *testing.T) {
+	if _, err := Open("csv", filepath.Join(t.TempDir(), "logs"), Rotation{}); err == nil {
+		t.Fatal("Open(csv) succeeded")
+	}
+}
@@ -0,0 +1,127 @@
+package logstore
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS game_logs (
+	id       INTEGER PRIMARY KEY,
+	sent_at  INTEGER NOT NULL,
+	game     TEXT NOT NULL,
+	username TEXT NOT NULL,
+	message  TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS game_logs_username ON game_logs (username, sent_at);
+CREATE INDEX IF NOT EXISTS game_logs_game ON game_logs (game, sent_at);
+CREATE INDEX IF NOT EXISTS game_logs_sent_at ON game_logs (sent_at);
+`
+
+// SQLiteSink stores the game logs in an embedded SQLite database, times are
+// kept as Unix nanoseconds.
+type SQLiteSink struct {
+	db *sql.DB
+}
+
+func OpenSQLite(path string) (*SQLiteSink, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open logs database: %v", err)
+	}
+	// SQLite only has one writer, sharing a connection avoids busy errors.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not create logs tables: %v", err)
+	}
+	return &SQLiteSink{db: db}, nil
+}
+
+// Write stores all the logs in one transaction.
+func (s *SQLiteSink) Write(logs ...routing.GameLog) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not store game logs: %v", err)
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare("INSERT INTO game_logs (sent_at, game, username, message) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return fmt.Errorf("could not store game logs: %v", err)
+	}
+	defer stmt.Close()
+	for _, gl := range logs {
+		if _, err := stmt.Exec(gl.CurrentTime.UnixNano(), gl.Game, gl.Username, gl.Message); err != nil {
+			return fmt.Errorf("could not store game log: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not store game logs: %v", err)
+	}
+	return nil
+}
+
+func (s *SQLiteSink) Query(f Filter) ([]routing.GameLog, error) {
+	where := []string{"1 = 1"}
+	args := []any{}
+	if f.Username != "" {
+		where = append(where, "username = ?")
+		args = append(args, f.Username)
+	}
+	if f.Game != "" {
+		where = append(where, "game = ?")
+		args = append(args, f.Game)
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "sent_at >= ?")
+		args = append(args, f.Since.UnixNano())
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "sent_at <= ?")
+		args = append(args, f.Until.UnixNano())
+	}
+	if f.Text != "" {
+		where = append(where, `message LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(f.Text)+"%")
+	}
+	limit := -1
+	if f.Limit > 0 {
+		limit = f.Limit
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.Query(
+		"SELECT sent_at, game, username, message FROM (SELECT id, sent_at, game, username, message FROM game_logs WHERE "+
+			strings.Join(where, " AND ")+" ORDER BY sent_at DESC, id DESC LIMIT ?) ORDER BY sent_at, id",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not query game logs: %v", err)
+	}
+	defer rows.Close()
+
+	logs := []routing.GameLog{}
+	for rows.Next() {
+		var sentAt int64
+		var gl routing.GameLog
+		if err := rows.Scan(&sentAt, &gl.Game, &gl.Username, &gl.Message); err != nil {
+			return nil, fmt.Errorf("could not read game log: %v", err)
+		}
+		gl.CurrentTime = time.Unix(0, sentAt)
+		logs = append(logs, gl)
+	}
+	return logs, rows.Err()
+}
+
+func (s *SQLiteSink) Close() error {
+	return s.db.Close()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,103 @@
+package logstore
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+var ErrWriterClosed = errors.New("the log writer is closed")
+
+type pending struct {
+	gl   routing.GameLog
+	done func(error)
+}
+
+// Writer batches the logs written to a sink in a goroutine. A batch is written
+// every interval, or as soon as it holds maxBatch logs, and the callback of
+// every log in it is then called with the outcome.
+type Writer struct {
+	sink     Sink
+	interval time.Duration
+	maxBatch int
+	in       chan pending
+	stopped  chan struct{}
+	closed   bool
+	mu       *sync.RWMutex
+}
+
+func NewWriter(sink Sink, interval time.Duration, maxBatch int) *Writer {
+	w := &Writer{
+		sink:     sink,
+		interval: interval,
+		maxBatch: maxBatch,
+		in:       make(chan pending, maxBatch),
+		stopped:  make(chan struct{}),
+		mu:       &sync.RWMutex{},
+	}
+	go w.run()
+	return w
+}
+
+// Write queues gl, done is called once it is durable or could not be written.
+func (w *Writer) Write(gl routing.GameLog, done func(error)) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		done(ErrWriterClosed)
+		return
+	}
+	w.in <- pending{gl: gl, done: done}
+}
+
+// Close writes the logs still queued and stops the writer, later writes fail
+// with ErrWriterClosed.
+func (w *Writer) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.in)
+	}
+	w.mu.Unlock()
+	<-w.stopped
+}
+
+func (w *Writer) run() {
+	defer close(w.stopped)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	batch := []pending{}
+	for {
+		select {
+		case p, ok := <-w.in:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, p)
+			if len(batch) >= w.maxBatch {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (w *Writer) flush(batch []pending) {
+	if len(batch) == 0 {
+		return
+	}
+	logs := make([]routing.GameLog, len(batch))
+	for i, p := range batch {
+		logs[i] = p.gl
+	}
+	err := w.sink.Write(logs...)
+	for _, p := range batch {
+		p.done(err)
+	}
+}
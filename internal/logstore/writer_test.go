@@ -0,0 +1,113 @@
+package logstore
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// batchSink records the batches it is given and fails them with err.
+type batchSink struct {
+	batches [][]string
+	err     error
+	mu      sync.Mutex
+}
+
+func (s *batchSink) Write(logs ...routing.GameLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, messages(logs))
+	return s.err
+}
+
+func (s *batchSink) written() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, batch := range s.batches {
+		n += len(batch)
+	}
+	return n
+}
+
+func (s *batchSink) Query(Filter) ([]routing.GameLog, error) { return nil, nil }
+func (s *batchSink) Close() error                            { return nil }
+
+func TestWriterBatchesAndAcksInOrder(t *testing.T) {
+	sink := &batchSink{}
+	w := NewWriter(sink, time.Hour, 3)
+	acks := []int{}
+	mu := sync.Mutex{}
+	for i := range 7 {
+		w.Write(gameLog(fmt.Sprint(i)), func(err error) {
+			if err != nil {
+				t.Errorf("log %d: %v", i, err)
+			}
+			if sink.written() <= i {
+				t.Errorf("log %d acked before it was written", i)
+			}
+			mu.Lock()
+			acks = append(acks, i)
+			mu.Unlock()
+		})
+	}
+	w.Close()
+
+	want := [][]string{{"0", "1", "2"}, {"3", "4", "5"}, {"6"}}
+	if !slices.EqualFunc(sink.batches, want, slices.Equal) {
+		t.Errorf("batches = %q, want %q", sink.batches, want)
+	}
+	if !slices.Equal(acks, []int{0, 1, 2, 3, 4, 5, 6}) {
+		t.Errorf("acks = %v, want every log in order", acks)
+	}
+}
+
+func TestWriterFlushesOnInterval(t *testing.T) {
+	sink := &batchSink{}
+	w := NewWriter(sink, 10*time.Millisecond, 100)
+	defer w.Close()
+	done := make(chan error, 1)
+	w.Write(gameLog("alone"), func(err error) { done <- err })
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("ack error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the log was not written within a second")
+	}
+}
+
+func TestWriterFailsTheWholeBatch(t *testing.T) {
+	errDiskFull := errors.New("disk full")
+	w := NewWriter(&batchSink{err: errDiskFull}, time.Hour, 2)
+	errs := make(chan error, 2)
+	for _, msg := range []string{"a", "b"} {
+		w.Write(gameLog(msg), func(err error) { errs <- err })
+	}
+	w.Close()
+	for range 2 {
+		if err := <-errs; !errors.Is(err, errDiskFull) {
+			t.Errorf("ack error = %v, want %v", err, errDiskFull)
+		}
+	}
+}
+
+func TestWriterClosed(t *testing.T) {
+	sink := &batchSink{}
+	w := NewWriter(sink, time.Hour, 10)
+	w.Close()
+	var got error
+	w.Write(gameLog("late"), func(err error) { got = err })
+	if !errors.Is(got, ErrWriterClosed) {
+		t.Errorf("ack error = %v, want %v", got, ErrWriterClosed)
+	}
+	if n := sink.written(); n != 0 {
+		t.Errorf("%d logs written after Close", n)
+	}
+}
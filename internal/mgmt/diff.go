@@ -0,0 +1,130 @@
+package mgmt
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+type Snapshot struct {
+	Exchanges []Exchange
+	Queues    []Queue
+	Bindings  []Binding
+}
+
+type IssueKind string
+
+const (
+	IssueMissing  IssueKind = "missing"
+	IssueOrphaned IssueKind = "orphaned"
+	IssueMismatch IssueKind = "mismatch"
+)
+
+type Issue struct {
+	Kind   IssueKind
+	Object string
+	Detail string
+}
+
+func (c *Client) Snapshot() (Snapshot, error) {
+	exchanges, err := c.Exchanges()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	queues, err := c.Queues()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	bindings, err := c.Bindings()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return Snapshot{Exchanges: exchanges, Queues: queues, Bindings: bindings}, nil
+}
+
+// Diff compares the broker state against the expected topology. Built-in
+// amq.* exchanges and default-exchange bindings are ignored.
+func Diff(expected routing.Topology, snap Snapshot) []Issue {
+	issues := []Issue{}
+
+	exchanges := map[string]Exchange{}
+	for _, ex := range snap.Exchanges {
+		exchanges[ex.Name] = ex
+	}
+	for _, spec := range expected.Exchanges {
+		ex, ok := exchanges[spec.Name]
+		if !ok {
+			issues = append(issues, Issue{IssueMissing, "exchange " + spec.Name, "not declared"})
+			continue
+		}
+		if ex.Type != spec.Kind {
+			issues = append(issues, Issue{IssueMismatch, "exchange " + spec.Name, fmt.Sprintf("type is %s, expected %s", ex.Type, spec.Kind)})
+		}
+	}
+
+	bindings := map[string]map[routing.BindingSpec]struct{}{}
+	for _, b := range snap.Bindings {
+		if b.Source == "" || b.DestinationType != "queue" {
+			continue
+		}
+		if bindings[b.Destination] == nil {
+			bindings[b.Destination] = map[routing.BindingSpec]struct{}{}
+		}
+		bindings[b.Destination][routing.BindingSpec{Exchange: b.Source, Key: b.RoutingKey}] = struct{}{}
+	}
+
+	seen := map[string]bool{}
+	for _, q := range snap.Queues {
+		spec, ok := matchQueueSpec(expected, q.Name)
+		if !ok {
+			issues = append(issues, Issue{IssueOrphaned, "queue " + q.Name, fmt.Sprintf("not declared by the game (%d consumers, %d messages)", q.Consumers, q.Messages)})
+			continue
+		}
+		seen[spec.Name] = true
+		if spec.Durable != q.Durable {
+			issues = append(issues, Issue{IssueMismatch, "queue " + q.Name, fmt.Sprintf("durable is %v, expected %v", q.Durable, spec.Durable)})
+		}
+		if !spec.Durable && q.Consumers == 0 {
+			issues = append(issues, Issue{IssueOrphaned, "queue " + q.Name, "transient queue has no consumers"})
+		}
+
+		expectedBindings := map[routing.BindingSpec]struct{}{}
+		for _, b := range spec.Expand(q.Name) {
+			expectedBindings[b] = struct{}{}
+			if _, ok := bindings[q.Name][b]; !ok {
+				issues = append(issues, Issue{IssueMissing, "binding " + describeBinding(b, q.Name), "not bound"})
+			}
+		}
+		for b := range bindings[q.Name] {
+			if _, ok := expectedBindings[b]; !ok {
+				issues = append(issues, Issue{IssueOrphaned, "binding " + describeBinding(b, q.Name), "not expected by the game"})
+			}
+		}
+	}
+
+	for _, spec := range expected.Queues {
+		if spec.Durable && len(spec.Placeholders) == 0 && !seen[spec.Name] {
+			issues = append(issues, Issue{IssueMissing, "queue " + spec.Name, "not declared"})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		return issues[i].Object < issues[j].Object
+	})
+	return issues
+}
+
+func matchQueueSpec(expected routing.Topology, name string) (routing.QueueSpec, bool) {
+	for _, spec := range expected.Queues {
+		if pubsub.MatchKey(spec.Name, name) {
+			return spec, true
+		}
+	}
+	return routing.QueueSpec{}, false
+}
+
+func describeBinding(b routing.BindingSpec, queue string) string {
+	return fmt.Sprintf("%s -[%s]-> %s", b.Exchange, b.Key, queue)
+}
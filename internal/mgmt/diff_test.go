@@ -0,0 +1,201 @@
+package mgmt
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+var testTopology = routing.Topology{
+	Exchanges: []routing.ExchangeSpec{
+		{Name: "peril_direct", Kind: "direct"},
+		{Name: "peril_topic", Kind: "topic"},
+	},
+	Queues: []routing.QueueSpec{
+		{
+			Name:     "game_logs",
+			Durable:  true,
+			Bindings: []routing.BindingSpec{{Exchange: "peril_topic", Key: "*.game_logs.*"}},
+		},
+		{
+			Name:     "join",
+			Durable:  true,
+			Bindings: []routing.BindingSpec{{Exchange: "peril_direct", Key: "join"}},
+		},
+		{
+			Name:         "client.*.*",
+			Placeholders: []string{routing.GamePlaceholder, routing.UsernamePlaceholder},
+			Bindings: []routing.BindingSpec{
+				{Exchange: "peril_direct", Key: routing.GameKey(routing.GamePlaceholder, "pause")},
+				{Exchange: "peril_topic", Key: routing.GameKey(routing.GamePlaceholder, "army_moves", routing.UsernamePlaceholder)},
+			},
+		},
+	},
+}
+
+// fakeBroker serves canned management API responses for the default vhost and
+// checks the credentials the client was given.
+func fakeBroker(t *testing.T, resources map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "guest" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		resource, ok := strings.CutPrefix(r.URL.EscapedPath(), "/api/")
+		body, found := resources[strings.TrimSuffix(resource, "/%2F")]
+		if !ok || !found || !strings.HasSuffix(resource, "/%2F") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func snapshotFrom(t *testing.T, resources map[string]string) Snapshot {
+	t.Helper()
+	srv := fakeBroker(t, resources)
+	c, err := NewClient(strings.Replace(srv.URL, "http://", "http://guest:secret@", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap, err := c.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snap
+}
+
+const healthyExchanges = `[
+	{"name": "", "type": "direct", "durable": true},
+	{"name": "amq.topic", "type": "topic", "durable": true},
+	{"name": "peril_direct", "type": "direct", "durable": true},
+	{"name": "peril_topic", "type": "topic", "durable": true}
+]`
+
+const healthyQueues = `[
+	{"name": "game_logs", "durable": true, "consumers": 1},
+	{"name": "join", "durable": true, "consumers": 1},
+	{"name": "client.g1.alice", "durable": false, "consumers": 1}
+]`
+
+const healthyBindings = `[
+	{"source": "", "destination": "join", "destination_type": "queue", "routing_key": "join"},
+	{"source": "peril_topic", "destination": "game_logs", "destination_type": "queue", "routing_key": "*.game_logs.*"},
+	{"source": "peril_direct", "destination": "join", "destination_type": "queue", "routing_key": "join"},
+	{"source": "peril_direct", "destination": "client.g1.alice", "destination_type": "queue", "routing_key": "g1.pause"},
+	{"source": "peril_topic", "destination": "client.g1.alice", "destination_type": "queue", "routing_key": "g1.army_moves.alice"}
+]`
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name      string
+		exchanges string
+		queues    string
+		bindings  string
+		want      []Issue
+	}{
+		{
+			name:      "healthy",
+			exchanges: healthyExchanges,
+			queues:    healthyQueues,
+			bindings:  healthyBindings,
+			want:      []Issue{},
+		},
+		{
+			name:      "missing exchange and queue",
+			exchanges: `[{"name": "peril_direct", "type": "direct"}]`,
+			queues:    `[{"name": "game_logs", "durable": true, "consumers": 1}]`,
+			bindings:  `[{"source": "peril_topic", "destination": "game_logs", "destination_type": "queue", "routing_key": "*.game_logs.*"}]`,
+			want: []Issue{
+				{IssueMissing, "exchange peril_topic", "not declared"},
+				{IssueMissing, "queue join", "not declared"},
+			},
+		},
+		{
+			name:      "extra queue and binding",
+			exchanges: healthyExchanges,
+			queues: `[
+				{"name": "game_logs", "durable": true, "consumers": 1},
+				{"name": "join", "durable": true, "consumers": 1},
+				{"name": "leftover", "durable": true, "consumers": 0, "messages": 3}
+			]`,
+			bindings: `[
+				{"source": "peril_topic", "destination": "game_logs", "destination_type": "queue", "routing_key": "*.game_logs.*"},
+				{"source": "peril_topic", "destination": "game_logs", "destination_type": "queue", "routing_key": "#"},
+				{"source": "peril_direct", "destination": "join", "destination_type": "queue", "routing_key": "join"}
+			]`,
+			want: []Issue{
+				{IssueOrphaned, "binding peril_topic -[#]-> game_logs", "not expected by the game"},
+				{IssueOrphaned, "queue leftover", "not declared by the game (0 consumers, 3 messages)"},
+			},
+		},
+		{
+			name:      "mismatched exchange, queue and bindings",
+			exchanges: `[{"name": "peril_direct", "type": "direct"}, {"name": "peril_topic", "type": "fanout"}]`,
+			queues: `[
+				{"name": "game_logs", "durable": false, "consumers": 1},
+				{"name": "join", "durable": true, "consumers": 1},
+				{"name": "client.g1.alice", "durable": false, "consumers": 0}
+			]`,
+			bindings: `[
+				{"source": "peril_topic", "destination": "game_logs", "destination_type": "queue", "routing_key": "*.game_logs.*"},
+				{"source": "peril_direct", "destination": "join", "destination_type": "queue", "routing_key": "join"},
+				{"source": "peril_direct", "destination": "client.g1.alice", "destination_type": "queue", "routing_key": "g1.pause"},
+				{"source": "peril_topic", "destination": "client.g1.alice", "destination_type": "queue", "routing_key": "g1.army_moves.bob"}
+			]`,
+			want: []Issue{
+				{IssueMissing, "binding peril_topic -[g1.army_moves.alice]-> client.g1.alice", "not bound"},
+				{IssueOrphaned, "binding peril_topic -[g1.army_moves.bob]-> client.g1.alice", "not expected by the game"},
+				{IssueMismatch, "exchange peril_topic", "type is fanout, expected topic"},
+				{IssueMismatch, "queue game_logs", "durable is false, expected true"},
+				{IssueOrphaned, "queue client.g1.alice", "transient queue has no consumers"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snap := snapshotFrom(t, map[string]string{
+				"exchanges": tt.exchanges,
+				"queues":    tt.queues,
+				"bindings":  tt.bindings,
+			})
+			got := Diff(testTopology, snap)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d issues, want %d:\n%v", len(got), len(tt.want), got)
+			}
+			for _, want := range tt.want {
+				if !containsIssue(got, want) {
+					t.Errorf("missing issue %+v in %v", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestClientRejectsBadCredentials(t *testing.T) {
+	srv := fakeBroker(t, map[string]string{"queues": "[]"})
+	c, err := NewClient(strings.Replace(srv.URL, "http://", "http://guest:wrong@", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Queues(); err == nil {
+		t.Fatal("expected an error for bad credentials")
+	}
+}
+
+func containsIssue(issues []Issue, want Issue) bool {
+	for _, issue := range issues {
+		if issue == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type DeadLetter struct {
+	ID         string
+	Exchange   string
+	RoutingKey string
+	Queue      string
+	Reason     string
+	Delivery   amqp.Delivery
+}
+
+// WalkDeadLetters fetches every message currently in the dead-letter queue and
+// passes it to fn. Messages for which fn returns Ack are removed from the
+// queue, everything else is put back once the walk is complete.
+func WalkDeadLetters(ch *amqp.Channel, fn func(DeadLetter) HandlerOutcome) error {
+	queue, err := ch.QueueDeclarePassive(routing.DeadLetterQueue, true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("could not inspect %s: %v", routing.DeadLetterQueue, err)
+	}
+
+	fetched := []amqp.Delivery{}
+	outcomes := []HandlerOutcome{}
+	defer func() {
+		for i, m := range fetched {
+			ackDelivery(m, outcomes[i])
+		}
+	}()
+
+	for i := 0; i < queue.Messages; i++ {
+		m, ok, err := ch.Get(routing.DeadLetterQueue, false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		fetched = append(fetched, m)
+		outcomes = append(outcomes, fn(newDeadLetter(i, m)))
+	}
+	return nil
+}
+
+func PurgeDeadLetters(ch *amqp.Channel) (int, error) {
+	return ch.QueuePurge(routing.DeadLetterQueue, false)
+}
+
+// Republish sends a dead letter back to the exchange and routing key it was
+// originally published to. Signed messages are refused: their receivers
+// already saw their ID, or will find them too old, and drop them, while
+// signing them again would make them pass for the operator's.
+func Republish(ch *amqp.Channel, dl DeadLetter) error {
+	if dl.Exchange == "" && dl.RoutingKey == "" {
+		return fmt.Errorf("message %s has no x-death origin", dl.ID)
+	}
+	if _, ok := dl.Delivery.Headers[SenderHeader]; ok {
+		return fmt.Errorf("message %s is signed, it can not be replayed", dl.ID)
+	}
+	headers := amqp.Table{}
+	for k, v := range dl.Delivery.Headers {
+		if k != "x-death" && k != "x-first-death-exchange" && k != "x-first-death-queue" && k != "x-first-death-reason" {
+			headers[k] = v
+		}
+	}
+	return ch.PublishWithContext(
+		context.Background(),
+		dl.Exchange,
+		dl.RoutingKey,
+		false,
+		false,
+		amqp.Publishing{
+			Headers:     headers,
+			ContentType: dl.Delivery.ContentType,
+			Body:        dl.Delivery.Body,
+			Priority:    dl.Delivery.Priority,
+			MessageId:   dl.Delivery.MessageId,
+			Timestamp:   dl.Delivery.Timestamp,
+		},
+	)
+}
+
+func newDeadLetter(index int, m amqp.Delivery) DeadLetter {
+	dl := DeadLetter{ID: m.MessageId, Delivery: m}
+	if dl.ID == "" {
+		dl.ID = strconv.Itoa(index + 1)
+	}
+
+	deaths, _ := m.Headers["x-death"].([]interface{})
+	if len(deaths) == 0 {
+		return dl
+	}
+	death, ok := deaths[0].(amqp.Table)
+	if !ok {
+		return dl
+	}
+	dl.Exchange, _ = death["exchange"].(string)
+	dl.Queue, _ = death["queue"].(string)
+	dl.Reason, _ = death["reason"].(string)
+	if keys, ok := death["routing-keys"].([]interface{}); ok && len(keys) > 0 {
+		dl.RoutingKey, _ = keys[0].(string)
+	}
+	return dl
+}
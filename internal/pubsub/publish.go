@@ -3,24 +3,57 @@ package pubsub
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"encoding/gob"
+	"encoding/hex"
 	"encoding/json"
+	"time"
 
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
-func publish[T any](ch *amqp.Channel, exchange, key string, val T, marshaller func(T) ([]byte, error), contentType string) error {
+// PublishOption changes a message before it is published to key on exchange.
+type PublishOption func(exchange, key string, p *amqp.Publishing)
+
+func WithPriority(priority uint8) PublishOption {
+	return func(exchange, key string, p *amqp.Publishing) {
+		p.Priority = priority
+	}
+}
+
+func newMessageID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(buf)
+}
+
+func publish[T any](
+	ch *amqp.Channel, exchange, key string, val T, marshaller func(T) ([]byte, error), contentType string, opts ...PublishOption,
+) error {
 	valBytes, err := marshaller(val)
 	if err != nil {
 		return err
 	}
+	msg := amqp.Publishing{
+		ContentType: contentType,
+		Body:        valBytes,
+		Priority:    routing.Priority(key),
+		MessageId:   newMessageID(),
+		Timestamp:   time.Now(),
+	}
+	for _, opt := range opts {
+		opt(exchange, key, &msg)
+	}
 	err = ch.PublishWithContext(
 		context.Background(),
 		exchange,
 		key,
 		false,
 		false,
-		amqp.Publishing{ContentType: contentType, Body: valBytes},
+		msg,
 	)
 	if err != nil {
 		return err
@@ -28,14 +61,14 @@ func publish[T any](ch *amqp.Channel, exchange, key string, val T, marshaller fu
 	return nil
 }
 
-func PublishJSON[T any](ch *amqp.Channel, exchange, key string, val T) error {
+func PublishJSON[T any](ch *amqp.Channel, exchange, key string, val T, opts ...PublishOption) error {
 	jsonMarshaller := func(T) ([]byte, error) {
 		return json.Marshal(val)
 	}
-	return publish(ch, exchange, key, val, jsonMarshaller, "application/json")
+	return publish(ch, exchange, key, val, jsonMarshaller, "application/json", opts...)
 }
 
-func PublishGob[T any](ch *amqp.Channel, exchange, key string, val T) error {
+func PublishGob[T any](ch *amqp.Channel, exchange, key string, val T, opts ...PublishOption) error {
 	gobMarshaller := func(T) ([]byte, error) {
 		var network bytes.Buffer
 		enc := gob.NewEncoder(&network)
@@ -44,5 +77,5 @@ func PublishGob[T any](ch *amqp.Channel, exchange, key string, val T) error {
 		}
 		return network.Bytes(), nil
 	}
-	return publish(ch, exchange, key, val, gobMarshaller, "application/gob")
+	return publish(ch, exchange, key, val, gobMarshaller, "application/gob", opts...)
 }
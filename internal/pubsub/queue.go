@@ -3,6 +3,7 @@ package pubsub
 import (
 	"fmt"
 
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
@@ -22,8 +23,16 @@ func (ss QueueType) String() string {
 	return queueName[ss]
 }
 
+type QueueOption func(amqp.Table)
+
+func WithMaxPriority(priority uint8) QueueOption {
+	return func(args amqp.Table) {
+		args["x-max-priority"] = int32(priority)
+	}
+}
+
 func DeclareAndBind(
-	conn *amqp.Connection, exchange, queueName, key string, simpleQueueType QueueType,
+	conn *amqp.Connection, exchange, queueName, key string, simpleQueueType QueueType, opts ...QueueOption,
 ) (*amqp.Channel, amqp.Queue, error) {
 	ch, err := conn.Channel()
 	if err != nil {
@@ -31,19 +40,27 @@ func DeclareAndBind(
 		return nil, amqp.Queue{}, err
 	}
 
+	args := amqp.Table{"x-dead-letter-exchange": routing.ExchangePerilDLX}
+	for _, opt := range opts {
+		opt(args)
+	}
+
 	queue, err := ch.QueueDeclare(
 		queueName,
 		simpleQueueType == QueueDurable,
 		simpleQueueType == QueueTransient,
 		simpleQueueType == QueueTransient,
 		false,
-		amqp.Table{"x-dead-letter-exchange": "peril_dlx"},
+		args,
 	)
 	if err != nil {
 		fmt.Println("Queue declaration failed:", err)
 		return nil, amqp.Queue{}, err
 	}
 
+	if exchange == routing.ExchangeDefault {
+		return ch, queue, nil
+	}
 	if err := ch.QueueBind(queue.Name, key, exchange, false, nil); err != nil {
 		fmt.Println("Queue binding failed:", err)
 		return nil, amqp.Queue{}, err
@@ -0,0 +1,157 @@
+package pubsub
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type route struct {
+	exchange string
+	pattern  string
+	dispatch func(amqp.Delivery) (HandlerOutcome, error)
+}
+
+type Router struct {
+	conn            *amqp.Connection
+	queueName       string
+	simpleQueueType QueueType
+	opts            []QueueOption
+	routes          []route
+}
+
+func NewRouter(conn *amqp.Connection, queueName string, simpleQueueType QueueType, opts ...QueueOption) *Router {
+	return &Router{
+		conn:            conn,
+		queueName:       queueName,
+		simpleQueueType: simpleQueueType,
+		opts:            opts,
+	}
+}
+
+// Handle registers handler for every message whose routing key matches pattern
+// on exchange. Bodies are decoded into T according to the delivery content type.
+func Handle[T any](r *Router, exchange, pattern string, handler func(T) HandlerOutcome) {
+	var zero T
+	r.routes = append(r.routes, route{
+		exchange: exchange,
+		pattern:  pattern,
+		dispatch: func(m amqp.Delivery) (HandlerOutcome, error) {
+			val, err := Unmarshal[T](m.ContentType, m.Body)
+			if err != nil {
+				return NackDiscard, fmt.Errorf("could not decode %T: %v", zero, err)
+			}
+			return handler(val), nil
+		},
+	})
+}
+
+// HandleFrom registers handler like Handle, but only for deliveries auth
+// accepts, and tells it who sent them. The others are discarded.
+func HandleFrom[T any](r *Router, exchange, pattern string, auth *Authenticator, handler func(string, T) HandlerOutcome) {
+	var zero T
+	r.routes = append(r.routes, route{
+		exchange: exchange,
+		pattern:  pattern,
+		dispatch: func(m amqp.Delivery) (HandlerOutcome, error) {
+			sender, err := auth.Check(m)
+			if err != nil {
+				return NackDiscard, err
+			}
+			val, err := Unmarshal[T](m.ContentType, m.Body)
+			if err != nil {
+				return NackDiscard, fmt.Errorf("could not decode %T: %v", zero, err)
+			}
+			return handler(sender, val), nil
+		},
+	})
+}
+
+func (r *Router) Run() error {
+	if len(r.routes) == 0 {
+		return fmt.Errorf("router for queue %s has no routes", r.queueName)
+	}
+
+	first := r.routes[0]
+	ch, queue, err := DeclareAndBind(r.conn, first.exchange, r.queueName, first.pattern, r.simpleQueueType, r.opts...)
+	if err != nil {
+		return err
+	}
+	for _, rt := range r.routes[1:] {
+		if err := ch.QueueBind(queue.Name, rt.pattern, rt.exchange, false, nil); err != nil {
+			return err
+		}
+	}
+
+	if err = ch.Qos(defaultPrefetch, 0, false); err != nil {
+		return err
+	}
+	deliveryChan, err := ch.Consume(queue.Name, "", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for m := range deliveryChan {
+			rt, ok := r.match(m.Exchange, m.RoutingKey)
+			if !ok {
+				log.Printf("no route for %s/%s on queue %s\n", m.Exchange, m.RoutingKey, r.queueName)
+				m.Nack(false, false)
+				continue
+			}
+			outcome, err := rt.dispatch(m)
+			if err != nil {
+				log.Printf("could not handle %s: %v\n", m.RoutingKey, err)
+			}
+			ackDelivery(m, outcome)
+		}
+	}()
+
+	return nil
+}
+
+func (r *Router) match(exchange, key string) (route, bool) {
+	for _, rt := range r.routes {
+		if rt.exchange == exchange && bindingMatches(exchange, rt.pattern, key) {
+			return rt, true
+		}
+	}
+	return route{}, false
+}
+
+// Only topic exchanges match keys against wildcards.
+func bindingMatches(exchange, pattern, key string) bool {
+	if exchange == routing.ExchangePerilTopic {
+		return MatchKey(pattern, key)
+	}
+	return pattern == key
+}
+
+// MatchKey reports whether key matches a topic binding pattern, where "*"
+// stands for exactly one word and "#" for zero or more words. Empty segments
+// count as words, so "*" matches the empty key.
+func MatchKey(pattern, key string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(key, "."))
+}
+
+func matchWords(pattern, key []string) bool {
+	if len(pattern) == 0 {
+		return len(key) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(key); i++ {
+			if matchWords(pattern[1:], key[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(key) > 0 && matchWords(pattern[1:], key[1:])
+	default:
+		return len(key) > 0 && pattern[0] == key[0] && matchWords(pattern[1:], key[1:])
+	}
+}
@@ -0,0 +1,79 @@
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func TestMatchKey(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"army_moves.alice", "army_moves.alice", true},
+		{"army_moves.alice", "army_moves.bob", false},
+		{"army_moves.*", "army_moves.bob", true},
+		{"army_moves.*", "army_moves", false},
+		{"army_moves.*", "army_moves.bob.extra", false},
+		{"*", "", true},
+		{"*", "a.b", false},
+		{"a.*.c", "a..c", true},
+		{"a.*", "a.", true},
+		{"#", "", true},
+		{"#", "a", true},
+		{"#", "a.b.c", true},
+		{"#", "#", true},
+		{"a.#", "a", true},
+		{"a.#", "a.b.c", true},
+		{"a.#", "b.a", false},
+		{"#.c", "c", true},
+		{"#.c", "a.b.c", true},
+		{"#.c", "a.b.d", false},
+		{"a.#.c", "a.c", true},
+		{"a.#.c", "a.b.b.c", true},
+		{"a.#.c", "a.b.b", false},
+		{"a.#.*", "a", false},
+		{"a.#.*", "a.b", true},
+		{"#.*.#", "", true},
+		{"a", "#", false},
+		{"*", "#", true},
+		{"#.*.#", "x", true},
+		{"g1.war.*", "g1.war.alice", true},
+		{"*.war.*", "g1.war.alice", true},
+		{"*.war.*", "war.alice", false},
+	}
+	for _, tt := range tests {
+		if got := MatchKey(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("MatchKey(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestRouterMatch(t *testing.T) {
+	r := NewRouter(nil, "client", QueueTransient)
+	handled := func(string) HandlerOutcome { return Ack }
+	Handle(r, routing.ExchangePerilDirect, "g1.*", handled)
+	Handle(r, routing.ExchangePerilDirect, "g1.pause", handled)
+	Handle(r, routing.ExchangePerilTopic, "g1.army_moves.*", handled)
+	tests := []struct {
+		exchange string
+		key      string
+		want     string
+		wantOK   bool
+	}{
+		{routing.ExchangePerilDirect, "g1.pause", "g1.pause", true},
+		{routing.ExchangePerilDirect, "g1.*", "g1.*", true},
+		{routing.ExchangePerilDirect, "g1.turn_started", "", false},
+		{routing.ExchangePerilTopic, "g1.army_moves.bob", "g1.army_moves.*", true},
+		{routing.ExchangePerilTopic, "g1.pause", "", false},
+		{routing.ExchangePerilDirect, "g1.army_moves.bob", "", false},
+	}
+	for _, tt := range tests {
+		rt, ok := r.match(tt.exchange, tt.key)
+		if ok != tt.wantOK || rt.pattern != tt.want {
+			t.Errorf("match(%s, %q) = %q, %v, want %q, %v", tt.exchange, tt.key, rt.pattern, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const directReplyQueue = "amq.rabbitmq.reply-to"
+
+// CallJSON publishes req and waits for the reply of a ServeJSON handler, using
+// RabbitMQ's direct reply-to so no reply queue has to be declared.
+func CallJSON[Req, Resp any](conn *amqp.Connection, exchange, key string, req Req, timeout time.Duration) (Resp, error) {
+	var resp Resp
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return resp, err
+	}
+	defer ch.Close()
+
+	replies, err := ch.Consume(directReplyQueue, "", true, false, false, false, nil)
+	if err != nil {
+		return resp, err
+	}
+
+	correlationID := newMessageID()
+	err = publish(ch, exchange, key, req, func(Req) ([]byte, error) {
+		return json.Marshal(req)
+	}, "application/json", func(_, _ string, p *amqp.Publishing) {
+		p.ReplyTo = directReplyQueue
+		p.CorrelationId = correlationID
+	})
+	if err != nil {
+		return resp, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return resp, fmt.Errorf("no reply to %s within %v", key, timeout)
+		case m, ok := <-replies:
+			if !ok {
+				return resp, fmt.Errorf("reply channel for %s closed", key)
+			}
+			if m.CorrelationId != correlationID {
+				continue
+			}
+			return jsonUnmarshal[Resp](m.Body)
+		}
+	}
+}
+
+// ServeJSON answers every request published to key with the value returned by
+// handler.
+func ServeJSON[Req, Resp any](
+	conn *amqp.Connection, exchange, queueName, key string, simpleQueueType QueueType, handler func(Req) Resp,
+) error {
+	ch, queue, err := DeclareAndBind(conn, exchange, queueName, key, simpleQueueType)
+	if err != nil {
+		return err
+	}
+	deliveryChan, err := ch.Consume(queue.Name, "", false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for m := range deliveryChan {
+			req, err := jsonUnmarshal[Req](m.Body)
+			if err != nil {
+				log.Printf("failed to unmarshal request %v. err: %v\n", m.Body, err)
+				m.Nack(false, false)
+				continue
+			}
+			if m.ReplyTo == "" {
+				log.Printf("request on %s has no reply-to, dropping it\n", key)
+				m.Nack(false, false)
+				continue
+			}
+
+			resp := handler(req)
+			err = publish(ch, "", m.ReplyTo, resp, func(Resp) ([]byte, error) {
+				return json.Marshal(resp)
+			}, "application/json", func(_, _ string, p *amqp.Publishing) {
+				p.CorrelationId = m.CorrelationId
+			})
+			if err != nil {
+				log.Printf("failed to reply to %s: %v\n", m.ReplyTo, err)
+				m.Nack(false, true)
+				continue
+			}
+			m.Ack(false)
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Signed messages name their sender and the key they were signed with in
+// these headers.
+const (
+	SenderHeader    = "x-peril-sender"
+	KeyIDHeader     = "x-peril-key-id"
+	SignatureHeader = "x-peril-signature"
+)
+
+var (
+	ErrUnsigned    = errors.New("message is not signed")
+	ErrStale       = errors.New("message is too old")
+	ErrReplayed    = errors.New("message was already handled")
+	ErrWrongSender = errors.New("message was published under another name")
+)
+
+// Verifier checks that sig was made over payload by sender, with the key
+// keyID names.
+type Verifier func(sender, keyID string, payload, sig []byte) error
+
+// SignedBy signs the message as sender with sign, which gets the payload to
+// sign. It must be the last option, since later changes are not signed.
+func SignedBy(sender, keyID string, sign func(payload []byte) []byte) PublishOption {
+	return func(exchange, key string, p *amqp.Publishing) {
+		if p.Headers == nil {
+			p.Headers = amqp.Table{}
+		}
+		p.Headers[SenderHeader] = sender
+		p.Headers[KeyIDHeader] = keyID
+		p.Headers[SignatureHeader] = sign(signedPayload(p.MessageId, p.Timestamp, exchange, key, sender, keyID, p.Body))
+	}
+}
+
+// signedPayload covers where a message goes and its ID and time as well as
+// its body, so a signed message can not be redirected or passed off as new.
+func signedPayload(id string, at time.Time, exchange, key, sender, keyID string, body []byte) []byte {
+	head := strings.Join([]string{id, strconv.FormatInt(at.Unix(), 10), exchange, key, sender, keyID}, "\n")
+	return append([]byte(head+"\n"), body...)
+}
+
+type AuthOption func(*Authenticator)
+
+// WithMaxAge rejects messages sent more than maxAge ago, or seen before
+// within that time. Redeliveries by the broker are let through.
+func WithMaxAge(maxAge time.Duration) AuthOption {
+	return func(a *Authenticator) {
+		a.maxAge = maxAge
+	}
+}
+
+// WithSenderInKey requires the last word of the routing key to be the
+// sender, as for everything players publish under their own name.
+func WithSenderInKey() AuthOption {
+	return func(a *Authenticator) {
+		a.senderInKey = true
+	}
+}
+
+// Authenticator checks the signatures of deliveries and tells handlers who
+// sent them.
+type Authenticator struct {
+	verify      Verifier
+	maxAge      time.Duration
+	senderInKey bool
+	seen        map[string]time.Time
+	pruned      time.Time
+	mu          *sync.Mutex
+}
+
+func NewAuthenticator(verify Verifier, opts ...AuthOption) *Authenticator {
+	a := &Authenticator{
+		verify: verify,
+		seen:   map[string]time.Time{},
+		mu:     &sync.Mutex{},
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Check returns the sender of a delivery with a valid signature.
+func (a *Authenticator) Check(m amqp.Delivery) (string, error) {
+	sender, _ := m.Headers[SenderHeader].(string)
+	keyID, _ := m.Headers[KeyIDHeader].(string)
+	sig, _ := m.Headers[SignatureHeader].([]byte)
+	if sender == "" || len(sig) == 0 {
+		return "", ErrUnsigned
+	}
+	if a.senderInKey && !strings.HasSuffix("."+m.RoutingKey, "."+sender) {
+		return "", ErrWrongSender
+	}
+	payload := signedPayload(m.MessageId, m.Timestamp, m.Exchange, m.RoutingKey, sender, keyID, m.Body)
+	if err := a.verify(sender, keyID, payload, sig); err != nil {
+		return "", fmt.Errorf("bad signature from %s: %w", sender, err)
+	}
+	if a.maxAge > 0 {
+		if err := a.fresh(m, time.Now()); err != nil {
+			return "", err
+		}
+	}
+	return sender, nil
+}
+
+func (a *Authenticator) fresh(m amqp.Delivery, now time.Time) error {
+	if m.Redelivered {
+		return nil
+	}
+	age := now.Sub(m.Timestamp)
+	if age > a.maxAge || age < -a.maxAge {
+		return ErrStale
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if now.Sub(a.pruned) > a.maxAge {
+		for id, at := range a.seen {
+			if now.Sub(at) > 2*a.maxAge {
+				delete(a.seen, id)
+			}
+		}
+		a.pruned = now
+	}
+	if _, ok := a.seen[m.MessageId]; ok {
+		return ErrReplayed
+	}
+	a.seen[m.MessageId] = now
+	return nil
+}
@@ -0,0 +1,117 @@
+package pubsub
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var errBadSignature = errors.New("bad signature")
+
+func hmacSign(key []byte) func([]byte) []byte {
+	return func(payload []byte) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write(payload)
+		return h.Sum(nil)
+	}
+}
+
+func hmacVerifier(keys map[string][]byte) Verifier {
+	return func(sender, keyID string, payload, sig []byte) error {
+		if !hmac.Equal(sig, hmacSign(keys[sender+"/"+keyID])(payload)) {
+			return errBadSignature
+		}
+		return nil
+	}
+}
+
+// signedDelivery publishes body as alice and returns what a consumer gets.
+func signedDelivery(key string, at time.Time, body string) amqp.Delivery {
+	p := amqp.Publishing{MessageId: newMessageID(), Timestamp: at, Body: []byte(body)}
+	SignedBy("alice", "k1", hmacSign([]byte("alice-key")))("peril_topic", key, &p)
+	return amqp.Delivery{
+		Headers:    p.Headers,
+		MessageId:  p.MessageId,
+		Timestamp:  p.Timestamp,
+		Exchange:   "peril_topic",
+		RoutingKey: key,
+		Body:       p.Body,
+	}
+}
+
+func TestAuthenticatorCheck(t *testing.T) {
+	keys := map[string][]byte{"alice/k1": []byte("alice-key")}
+	now := time.Now()
+	tests := []struct {
+		name   string
+		change func(m *amqp.Delivery)
+		want   error
+	}{
+		{"valid", func(m *amqp.Delivery) {}, nil},
+		{"unsigned", func(m *amqp.Delivery) { m.Headers = nil }, ErrUnsigned},
+		{"tampered body", func(m *amqp.Delivery) { m.Body = []byte("spawn 100 tanks") }, errBadSignature},
+		{"redirected", func(m *amqp.Delivery) { m.Exchange = "peril_direct" }, errBadSignature},
+		{"another key ID", func(m *amqp.Delivery) { m.Headers[KeyIDHeader] = "k2" }, errBadSignature},
+		{"claims another sender", func(m *amqp.Delivery) {
+			m.Headers[SenderHeader] = "bob"
+			m.RoutingKey = "game.spawn_orders.bob"
+		}, errBadSignature},
+		{"key of another player", func(m *amqp.Delivery) { m.RoutingKey = "game.spawn_orders.bob" }, ErrWrongSender},
+		{"stale", func(m *amqp.Delivery) { *m = signedDelivery(m.RoutingKey, now.Add(-time.Hour), "spawn") }, ErrStale},
+		{"from the future", func(m *amqp.Delivery) { *m = signedDelivery(m.RoutingKey, now.Add(time.Hour), "spawn") }, ErrStale},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewAuthenticator(hmacVerifier(keys), WithSenderInKey(), WithMaxAge(time.Minute))
+			m := signedDelivery("game.spawn_orders.alice", now, "spawn")
+			tc.change(&m)
+			sender, err := a.Check(m)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("Check() error = %v, want %v", err, tc.want)
+			}
+			if err == nil && sender != "alice" {
+				t.Errorf("Check() sender = %q, want alice", sender)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorReplay(t *testing.T) {
+	keys := map[string][]byte{"alice/k1": []byte("alice-key")}
+	a := NewAuthenticator(hmacVerifier(keys), WithMaxAge(time.Minute))
+	m := signedDelivery("game.spawn_orders.alice", time.Now(), "spawn")
+	if _, err := a.Check(m); err != nil {
+		t.Fatalf("first Check() error = %v", err)
+	}
+	if _, err := a.Check(m); !errors.Is(err, ErrReplayed) {
+		t.Fatalf("replayed Check() error = %v, want %v", err, ErrReplayed)
+	}
+	m.Redelivered = true
+	if _, err := a.Check(m); err != nil {
+		t.Fatalf("redelivered Check() error = %v", err)
+	}
+}
+
+func TestSignedPayloadCoversEverything(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	base := signedPayload("id", at, "ex", "key", "alice", "k1", []byte("body"))
+	others := [][]byte{
+		signedPayload("id2", at, "ex", "key", "alice", "k1", []byte("body")),
+		signedPayload("id", at.Add(time.Second), "ex", "key", "alice", "k1", []byte("body")),
+		signedPayload("id", at, "ex2", "key", "alice", "k1", []byte("body")),
+		signedPayload("id", at, "ex", "key2", "alice", "k1", []byte("body")),
+		signedPayload("id", at, "ex", "key", "bob", "k1", []byte("body")),
+		signedPayload("id", at, "ex", "key", "alice", "k2", []byte("body")),
+		signedPayload("id", at, "ex", "key", "alice", "k1", []byte("body2")),
+	}
+	for i, other := range others {
+		if bytes.Equal(base, other) {
+			t.Errorf("payload %d equals the base payload", i)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/gob"
 	"encoding/json"
+	"fmt"
 	"log"
 
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -27,21 +28,30 @@ func (o HandlerOutcome) String() string {
 	return outcomeName[o]
 }
 
+const defaultPrefetch = 10
+
+// Settle acks or nacks a delivery whose handler finished with it later, it may
+// be called from any goroutine but only once.
+type Settle func(HandlerOutcome)
+
 func subscribe[T any](
 	conn *amqp.Connection,
 	exchange,
 	queueName,
 	key string,
 	simpleQueueType QueueType,
-	handler func(T) HandlerOutcome,
+	prefetch int,
+	auth *Authenticator,
+	handler func(string, T, Settle),
 	unmarshaller func([]byte) (T, error),
+	opts ...QueueOption,
 ) error {
-	ch, queue, err := DeclareAndBind(conn, exchange, queueName, key, simpleQueueType)
+	ch, queue, err := DeclareAndBind(conn, exchange, queueName, key, simpleQueueType, opts...)
 	if err != nil {
 		return err
 	}
 
-	if err = ch.Qos(10, 0, false); err != nil {
+	if err = ch.Qos(prefetch, 0, false); err != nil {
 		return err
 	}
 	deliveryChan, err := ch.Consume(queue.Name, "", false, false, false, false, nil)
@@ -51,49 +61,117 @@ func subscribe[T any](
 
 	go func() {
 		for m := range deliveryChan {
-			val, err := unmarshaller(m.Body)
-			if err != nil {
-				log.Printf("failed to unmarshal body %v. err: %v\n", m.Body, err)
-				continue
-			}
-
-			switch handler(val) {
-			case Ack:
-				m.Ack(false)
-			case NackRequeue:
-				m.Nack(false, true)
-			case NackDiscard:
-				m.Nack(false, false)
-			}
+			deliver(m, auth, handler, unmarshaller)
 		}
 	}()
 
 	return nil
 }
 
+// Deliveries that can never be handled are discarded rather than left
+// unacked, which would stall the queue once prefetch of them piled up.
+func deliver[T any](m amqp.Delivery, auth *Authenticator, handler func(string, T, Settle), unmarshaller func([]byte) (T, error)) {
+	sender, err := "", error(nil)
+	if auth != nil {
+		if sender, err = auth.Check(m); err != nil {
+			log.Printf("rejected %s: %v\n", m.RoutingKey, err)
+			m.Nack(false, false)
+			return
+		}
+	}
+	val, err := unmarshaller(m.Body)
+	if err != nil {
+		log.Printf("failed to unmarshal body %v. err: %v\n", m.Body, err)
+		m.Nack(false, false)
+		return
+	}
+
+	handler(sender, val, func(outcome HandlerOutcome) {
+		ackDelivery(m, outcome)
+	})
+}
+
+func settleNow[T any](handler func(T) HandlerOutcome) func(string, T, Settle) {
+	return func(_ string, val T, settle Settle) {
+		settle(handler(val))
+	}
+}
+
+func settleNowFrom[T any](handler func(string, T) HandlerOutcome) func(string, T, Settle) {
+	return func(sender string, val T, settle Settle) {
+		settle(handler(sender, val))
+	}
+}
+
+func ackDelivery(m amqp.Delivery, outcome HandlerOutcome) {
+	switch outcome {
+	case Ack:
+		m.Ack(false)
+	case NackRequeue:
+		m.Nack(false, true)
+	case NackDiscard:
+		m.Nack(false, false)
+	}
+}
+
+func jsonUnmarshal[T any](raw []byte) (T, error) {
+	var val T
+	if err := json.Unmarshal(raw, &val); err != nil {
+		return val, err
+	}
+	return val, nil
+}
+
+func gobUnmarshal[T any](raw []byte) (T, error) {
+	dec := gob.NewDecoder(bytes.NewBuffer(raw))
+	var val T
+	if err := dec.Decode(&val); err != nil {
+		return val, err
+	}
+	return val, nil
+}
+
+func Unmarshal[T any](contentType string, raw []byte) (T, error) {
+	switch contentType {
+	case "application/json":
+		return jsonUnmarshal[T](raw)
+	case "application/gob":
+		return gobUnmarshal[T](raw)
+	default:
+		var val T
+		return val, fmt.Errorf("unsupported content type %q", contentType)
+	}
+}
+
 func SubscribeJSON[T any](
 	conn *amqp.Connection, exchange, queueName, key string, simpleQueueType QueueType, handler func(T) HandlerOutcome,
+	opts ...QueueOption,
 ) error {
-	unmarshaller := func(raw []byte) (T, error) {
-		var val T
-		if err := json.Unmarshal(raw, &val); err != nil {
-			return val, err
-		}
-		return val, nil
-	}
-	return subscribe(conn, exchange, queueName, key, simpleQueueType, handler, unmarshaller)
+	return subscribe(conn, exchange, queueName, key, simpleQueueType, defaultPrefetch, nil, settleNow(handler), jsonUnmarshal[T], opts...)
+}
+
+// SubscribeJSONFrom only hands over deliveries auth accepts, along with their
+// sender. The others are discarded.
+func SubscribeJSONFrom[T any](
+	conn *amqp.Connection, exchange, queueName, key string, simpleQueueType QueueType, auth *Authenticator,
+	handler func(string, T) HandlerOutcome, opts ...QueueOption,
+) error {
+	return subscribe(conn, exchange, queueName, key, simpleQueueType, defaultPrefetch, auth, settleNowFrom(handler), jsonUnmarshal[T], opts...)
 }
 
 func SubscribeGob[T any](
 	conn *amqp.Connection, exchange, queueName, key string, simpleQueueType QueueType, handler func(T) HandlerOutcome,
+	opts ...QueueOption,
 ) error {
-	unmarshaller := func(raw []byte) (T, error) {
-		dec := gob.NewDecoder(bytes.NewBuffer(raw))
-		var val T
-		if err := dec.Decode(&val); err != nil {
-			return val, err
-		}
-		return val, nil
-	}
-	return subscribe(conn, exchange, queueName, key, simpleQueueType, handler, unmarshaller)
+	return subscribe(conn, exchange, queueName, key, simpleQueueType, defaultPrefetch, nil, settleNow(handler), gobUnmarshal[T], opts...)
+}
+
+// SubscribeGobDeferred lets handler ack its deliveries later, for instance once
+// a batch of them has been written out. Up to prefetch deliveries are handed
+// over before the first one is settled. Deliveries auth rejects are discarded.
+func SubscribeGobDeferred[T any](
+	conn *amqp.Connection, exchange, queueName, key string, simpleQueueType QueueType, prefetch int, auth *Authenticator,
+	handler func(string, T, Settle), opts ...QueueOption,
+) error {
+	return subscribe(conn, exchange, queueName, key, simpleQueueType, prefetch, auth, handler, gobUnmarshal[T], opts...)
 }
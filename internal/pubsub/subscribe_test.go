@@ -0,0 +1,59 @@
+package pubsub
+
+import (
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// settlements records how the deliveries it acknowledges were settled.
+type settlements []string
+
+func (s *settlements) Ack(tag uint64, multiple bool) error {
+	*s = append(*s, "ack")
+	return nil
+}
+
+func (s *settlements) Nack(tag uint64, multiple, requeue bool) error {
+	*s = append(*s, map[bool]string{true: "nack-requeue", false: "nack-discard"}[requeue])
+	return nil
+}
+
+func (s *settlements) Reject(tag uint64, requeue bool) error {
+	*s = append(*s, "reject")
+	return nil
+}
+
+func TestDeliver(t *testing.T) {
+	type order struct{ Unit int }
+	tests := []struct {
+		name    string
+		body    string
+		outcome HandlerOutcome
+		want    string
+		handled bool
+	}{
+		{"acked", `{"Unit":1}`, Ack, "ack", true},
+		{"requeued", `{"Unit":1}`, NackRequeue, "nack-requeue", true},
+		{"discarded", `{"Unit":1}`, NackDiscard, "nack-discard", true},
+		{"can not be unmarshalled", `{"Unit":`, Ack, "nack-discard", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			acks := &settlements{}
+			m := amqp.Delivery{Acknowledger: acks, DeliveryTag: 1, Body: []byte(tc.body)}
+			handled := false
+			deliver(m, nil, settleNow(func(order) HandlerOutcome {
+				handled = true
+				return tc.outcome
+			}), jsonUnmarshal[order])
+
+			if handled != tc.handled {
+				t.Errorf("handled = %v, want %v", handled, tc.handled)
+			}
+			if len(*acks) != 1 || (*acks)[0] != tc.want {
+				t.Errorf("settled %v, want only %s", *acks, tc.want)
+			}
+		})
+	}
+}
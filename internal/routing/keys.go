@@ -0,0 +1,61 @@
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	maxGameIDLength   = 32
+	minUsernameLength = 3
+	maxUsernameLength = 20
+)
+
+// GameKey scopes a routing key or queue name to a game room, e.g.
+// GameKey("g1", ArmyMovesPrefix, "bob") is "g1.army_moves.bob".
+func GameKey(game string, parts ...string) string {
+	return strings.Join(append([]string{game}, parts...), ".")
+}
+
+// ValidateGameID checks that id can be used as a routing key segment and can
+// not be mistaken for a message category or queue name.
+func ValidateGameID(id string) error {
+	if id == "" {
+		return errors.New("the game ID must not be empty")
+	}
+	if len(id) > maxGameIDLength {
+		return fmt.Errorf("the game ID must be at most %d characters long", maxGameIDLength)
+	}
+	if !isSegment(id) {
+		return fmt.Errorf("the game ID %q may only contain lowercase letters, digits, - and _", id)
+	}
+	if categoryPriority(id) > 0 {
+		return fmt.Errorf("%q is reserved", id)
+	}
+	switch id {
+	case ClientQueuePrefix, LobbyQueuePrefix, OrdersQueue, RoomsQueue, AuthQueue, DeadLetterQueue:
+		return fmt.Errorf("%q is reserved", id)
+	}
+	return nil
+}
+
+// ValidateUsername checks that username can be used as a routing key segment.
+func ValidateUsername(username string) error {
+	if len(username) < minUsernameLength || len(username) > maxUsernameLength {
+		return fmt.Errorf("usernames are %d to %d characters long", minUsernameLength, maxUsernameLength)
+	}
+	if !isSegment(username) {
+		return fmt.Errorf("the username %q may only contain lowercase letters, digits, - and _", username)
+	}
+	return nil
+}
+
+func isSegment(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' && r != '_' {
+			return false
+		}
+	}
+	return true
+}
@@ -2,12 +2,74 @@ package routing
 
 import "time"
 
+// PlayingState is broadcast whenever the server pauses, resumes or switches
+// between real-time and turn-based play.
 type PlayingState struct {
-	IsPaused bool
+	IsPaused     bool
+	TurnBased    bool
+	TurnDuration time.Duration
+}
+
+type TurnStarted struct {
+	Turn     int
+	Duration time.Duration
+}
+
+type TurnEnded struct {
+	Turn int
 }
 
 type GameLog struct {
 	CurrentTime time.Time
 	Message     string
 	Username    string
+	Game        string
+}
+
+// HeartbeatInterval is how often clients announce they are still online. The
+// server considers a player gone after missing a few heartbeats.
+const HeartbeatInterval = 5 * time.Second
+
+type Heartbeat struct {
+	Username string
+	// SentAt is informational only, the server goes by when heartbeats arrive.
+	SentAt  time.Time
+	Leaving bool
+}
+
+// PresenceEvent is broadcast when a player comes online or goes offline.
+type PresenceEvent struct {
+	Username string
+	Online   bool
+	At       time.Time
+}
+
+// Admin messages are sent by the server operator. Kick, Ban and Mute target a
+// single player, Broadcast reaches every player on the server and Announcement
+// the players of one game.
+type Kick struct {
+	Username string
+	Reason   string
+}
+
+type Ban struct {
+	Username string
+	Reason   string
+	BannedAt time.Time
+}
+
+type Mute struct {
+	Username string
+	Muted    bool
+}
+
+type Broadcast struct {
+	Message string
+	SentAt  time.Time
+}
+
+type Announcement struct {
+	Game    string
+	Message string
+	SentAt  time.Time
 }
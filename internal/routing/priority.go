@@ -0,0 +1,45 @@
+package routing
+
+import "strings"
+
+const MaxPriority uint8 = 10
+
+const (
+	PriorityLog     uint8 = 1
+	PriorityMove    uint8 = 3
+	PriorityWar     uint8 = 5
+	PriorityControl uint8 = 9
+)
+
+// Priority returns the delivery priority for a routing key based on its
+// message category, so control-plane messages overtake gameplay backlogs.
+func Priority(key string) uint8 {
+	return categoryPriority(Category(key))
+}
+
+// Category returns the message category of a routing key, skipping the game
+// ID of game-scoped keys.
+func Category(key string) string {
+	first, rest, _ := strings.Cut(key, ".")
+	if categoryPriority(first) > 0 {
+		return first
+	}
+	second, _, _ := strings.Cut(rest, ".")
+	return second
+}
+
+func categoryPriority(category string) uint8 {
+	switch category {
+	case PauseKey, JoinQueue, AuthQueue, RoomsQueue, MatchmakingQueue, MatchesPrefix, PresencePrefix, RosterPrefix, TurnStartedKey,
+		KickPrefix, BanPrefix, MutePrefix, BroadcastKey, AnnounceKey, TurnEndedKey, GameOverKey:
+		return PriorityControl
+	case WarResultsPrefix, DiplomacyPrefix, AlliancesKey:
+		return PriorityWar
+	case ArmyMovesPrefix, SpawnOrdersPrefix, MoveOrdersPrefix, StateDeltasPrefix:
+		return PriorityMove
+	case GameLogSlug:
+		return PriorityLog
+	default:
+		return 0
+	}
+}
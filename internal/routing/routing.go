@@ -3,14 +3,76 @@ package routing
 const (
 	ArmyMovesPrefix = "army_moves"
 
-	WarRecognitionsPrefix = "war"
+	WarResultsPrefix = "war_results"
 
 	PauseKey = "pause"
 
+	MatchesPrefix = "matches"
+
+	TurnStartedKey = "turn_started"
+
+	TurnEndedKey = "turn_ended"
+
+	GameOverKey = "game_over"
+
 	GameLogSlug = "game_logs"
+
+	SpawnOrdersPrefix = "spawn_orders"
+
+	MoveOrdersPrefix = "move_orders"
+
+	StateDeltasPrefix = "state"
+
+	DiplomacyPrefix = "diplomacy"
+
+	AlliancesKey = "alliances"
+
+	PresencePrefix = "presence"
+
+	RosterPrefix = "roster"
+
+	KickPrefix = "kick"
+
+	BanPrefix = "ban"
+
+	MutePrefix = "mute"
+
+	BroadcastKey = "broadcast"
+
+	AnnounceKey = "announce"
 )
 
+// ServerName is the sender of the messages the server signs.
+const ServerName = "server"
+
+// ExchangeDefault delivers straight to the queue named by the routing key.
+// Nothing can be bound to it, so requests carrying passwords and session
+// tokens are sent through it to queues only the server can consume.
+const ExchangeDefault = ""
+
 const (
 	ExchangePerilDirect = "peril_direct"
 	ExchangePerilTopic  = "peril_topic"
+	ExchangePerilDLX    = "peril_dlx"
+)
+
+const (
+	DeadLetterQueue = "peril_dlq"
+)
+
+const (
+	ClientQueuePrefix = "client"
+
+	OrdersQueue = "orders"
+
+	// The RPC queues are exclusive to the server, see ExchangeDefault.
+	JoinQueue = "join"
+
+	AuthQueue = "auth"
+
+	RoomsQueue = "rooms"
+
+	MatchmakingQueue = "matchmaking"
+
+	LobbyQueuePrefix = "lobby"
 )
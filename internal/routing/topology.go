@@ -0,0 +1,129 @@
+package routing
+
+import "strings"
+
+type ExchangeSpec struct {
+	Name string
+	Kind string
+}
+
+type BindingSpec struct {
+	Exchange string
+	Key      string
+}
+
+// Placeholders stand for the game ID and the player's username in the names
+// and binding keys of per-game and per-player queues.
+const (
+	GamePlaceholder     = "{game}"
+	UsernamePlaceholder = "{username}"
+)
+
+// QueueSpec describes a queue the game declares. Per-game and per-player
+// queues use "*" in place of the game ID and username, and Placeholders names
+// what each "*" of the queue name stands for, in order.
+type QueueSpec struct {
+	Name         string
+	Durable      bool
+	Placeholders []string
+	Bindings     []BindingSpec
+}
+
+type Topology struct {
+	Exchanges []ExchangeSpec
+	Queues    []QueueSpec
+}
+
+func ExpectedTopology() Topology {
+	return Topology{
+		Exchanges: []ExchangeSpec{
+			{Name: ExchangePerilDirect, Kind: "direct"},
+			{Name: ExchangePerilTopic, Kind: "topic"},
+			{Name: ExchangePerilDLX, Kind: "fanout"},
+		},
+		Queues: []QueueSpec{
+			{
+				Name:     GameLogSlug,
+				Durable:  true,
+				Bindings: []BindingSpec{{Exchange: ExchangePerilTopic, Key: GameKey("*", GameLogSlug, "*")}},
+			},
+			{Name: JoinQueue},
+			{Name: AuthQueue},
+			{Name: RoomsQueue},
+			{Name: MatchmakingQueue},
+			{
+				Name:         LobbyQueuePrefix + ".*",
+				Placeholders: []string{UsernamePlaceholder},
+				Bindings:     []BindingSpec{{Exchange: ExchangePerilDirect, Key: MatchesPrefix + "." + UsernamePlaceholder}},
+			},
+			{
+				Name:     DeadLetterQueue,
+				Durable:  true,
+				Bindings: []BindingSpec{{Exchange: ExchangePerilDLX, Key: ""}},
+			},
+			{
+				Name:         GameKey("*", DiplomacyPrefix),
+				Durable:      true,
+				Placeholders: []string{GamePlaceholder},
+				Bindings:     []BindingSpec{{Exchange: ExchangePerilTopic, Key: GameKey(GamePlaceholder, DiplomacyPrefix, "*")}},
+			},
+			{
+				Name:         GameKey("*", PresencePrefix),
+				Durable:      true,
+				Placeholders: []string{GamePlaceholder},
+				Bindings:     []BindingSpec{{Exchange: ExchangePerilTopic, Key: GameKey(GamePlaceholder, PresencePrefix, "*")}},
+			},
+			{
+				Name:         GameKey("*", OrdersQueue),
+				Durable:      true,
+				Placeholders: []string{GamePlaceholder},
+				Bindings: []BindingSpec{
+					{Exchange: ExchangePerilTopic, Key: GameKey(GamePlaceholder, SpawnOrdersPrefix, "*")},
+					{Exchange: ExchangePerilTopic, Key: GameKey(GamePlaceholder, MoveOrdersPrefix, "*")},
+				},
+			},
+			{
+				Name:         ClientQueuePrefix + ".*.*",
+				Placeholders: []string{GamePlaceholder, UsernamePlaceholder},
+				Bindings: []BindingSpec{
+					{Exchange: ExchangePerilDirect, Key: GameKey(GamePlaceholder, PauseKey)},
+					{Exchange: ExchangePerilDirect, Key: GameKey(GamePlaceholder, TurnStartedKey)},
+					{Exchange: ExchangePerilDirect, Key: GameKey(GamePlaceholder, TurnEndedKey)},
+					{Exchange: ExchangePerilDirect, Key: GameKey(GamePlaceholder, GameOverKey)},
+					{Exchange: ExchangePerilDirect, Key: GameKey(GamePlaceholder, AnnounceKey)},
+					{Exchange: ExchangePerilDirect, Key: GameKey(GamePlaceholder, AlliancesKey)},
+					{Exchange: ExchangePerilDirect, Key: BroadcastKey},
+					{Exchange: ExchangePerilDirect, Key: KickPrefix + "." + UsernamePlaceholder},
+					{Exchange: ExchangePerilDirect, Key: BanPrefix + "." + UsernamePlaceholder},
+					{Exchange: ExchangePerilDirect, Key: MutePrefix + "." + UsernamePlaceholder},
+					{Exchange: ExchangePerilTopic, Key: GameKey(GamePlaceholder, ArmyMovesPrefix, UsernamePlaceholder)},
+					{Exchange: ExchangePerilTopic, Key: GameKey(GamePlaceholder, StateDeltasPrefix, UsernamePlaceholder)},
+					{Exchange: ExchangePerilTopic, Key: GameKey(GamePlaceholder, WarResultsPrefix, UsernamePlaceholder)},
+					{Exchange: ExchangePerilTopic, Key: GameKey(GamePlaceholder, RosterPrefix, "*")},
+				},
+			},
+		},
+	}
+}
+
+// Expand replaces the placeholders in the binding keys of spec with the values
+// the "*" segments of queue stand for.
+func (spec QueueSpec) Expand(queue string) []BindingSpec {
+	values := []string{}
+	names := strings.Split(queue, ".")
+	for i, segment := range strings.Split(spec.Name, ".") {
+		if segment == "*" && i < len(names) {
+			values = append(values, names[i])
+		}
+	}
+	bindings := []BindingSpec{}
+	for _, b := range spec.Bindings {
+		for i, placeholder := range spec.Placeholders {
+			if i < len(values) {
+				b.Key = strings.ReplaceAll(b.Key, placeholder, values[i])
+			}
+		}
+		bindings = append(bindings, b)
+	}
+	return bindings
+}